@@ -2,22 +2,33 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/jwtly10/at4j-risk-manager/internal/api"
 	"github.com/jwtly10/at4j-risk-manager/internal/broker"
 	"github.com/jwtly10/at4j-risk-manager/internal/config"
 	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/events"
 	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
 	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
 	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
 
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 5 * time.Second
+
 func main() {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -38,33 +49,45 @@ func main() {
 	}
 	defer conn.Close()
 
+	// Handle the migrate subcommand, e.g. `main migrate up`, without starting the service
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, conn, os.Args[2:]); err != nil {
+			logger.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	if cfg.DB.MigrateOnStartup {
+		if err := runMigrate(ctx, conn, []string{"up"}); err != nil {
+			logger.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+
 	dbClient := db.NewDBClient(conn)
 
-	// Configure broker specific time configs
-	configs := map[string]jobs.BrokerTimeConfig{
-		"OANDA": {
-			Timezone:          "UTC",
-			DailyUpdateHour:   00,
-			DailyUpdateMinute: 1,
-		},
-		"MT5_FTMO": {
-			Timezone:          "Europe/Prague",
-			DailyUpdateHour:   00,
-			DailyUpdateMinute: 1,
-		},
+	settings, err := buildSettings(cfg)
+	if err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
 	}
 
-	notifier := notifications.NewTelegramNotifier(&cfg.Telegram)
+	channels, err := notifications.NewNotifier(cfg.Notifications)
+	if err != nil {
+		logger.Fatalf("Failed to configure notifications: %v", err)
+	}
+	// Notification channels are swapped when the configuration is reloaded
+	notifier := notifications.NewSwitchNotifier(channels)
+
+	// Configure adapters of the enabled brokers and named broker connections
+	connections, err := broker.LoadConnections(http.DefaultClient, cfg.Brokers)
+	if err != nil {
+		logger.Fatalf("Failed to configure broker connections: %v", err)
+	}
 
-	// Configure broker adapters
-	brokerAdapters := make(map[string]broker.BrokerAdapter)
-	oandaAdapter, _ := broker.NewAdapter(http.DefaultClient, broker.Oanda, cfg.Brokers)
-	brokerAdapters[broker.Oanda] = oandaAdapter
-	ftmoAdapter, _ := broker.NewAdapter(http.DefaultClient, broker.MT5FTMO, cfg.Brokers)
-	brokerAdapters[broker.MT5FTMO] = ftmoAdapter
+	// Live equity and risk updates, streamed to API clients
+	hub := events.NewHub()
 
 	// Start equity tracker job
-	tracker := jobs.NewEquityTracker(dbClient, configs, notifier, brokerAdapters, time.Duration(cfg.Jobs.EquityCheckInterval)*time.Second)
+	tracker := jobs.NewEquityTracker(dbClient, settings.catalogue, settings.defaultProfiles, notifier, hub, connections, settings.tracker)
 	go func() {
 		if err := tracker.Start(); err != nil {
 			logger.Errorf("Error starting equity tracker: %v", err)
@@ -72,8 +95,16 @@ func main() {
 		}
 	}()
 
+	// Reload the configuration when the config file changes or on SIGHUP
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	current := cfg
+	go config.Watch(ctx, os.Getenv("CONFIG_FILE"), configPollInterval, reloadChan, func(next *config.Config, err error) {
+		current = reloadConfig(current, next, err, tracker, notifier)
+	})
+
 	// Start API server
-	server := api.NewServer(cfg, dbClient)
+	server := api.NewServer(cfg, dbClient, tracker, hub)
 	go func() {
 		if err := server.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Errorf("HTTP server error: %v", err)
@@ -99,8 +130,11 @@ func main() {
 	// Stop jobs
 	tracker.Stop()
 
+	// End open streams, as the server waits for all requests to finish
+	hub.Close()
+
 	// Stop API server
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Errorf("Error shutting down HTTP server: %v", err)
 	}
 
@@ -108,3 +142,140 @@ func main() {
 
 	logger.Infof("Service stopped")
 }
+
+// runMigrate runs a migration command: up, down [steps] or version
+func runMigrate(ctx context.Context, conn *sql.DB, args []string) error {
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [steps] | version")
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Applied %d migrations", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Reverted %d migrations", n)
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate command: %s", args[0])
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Database schema at version %d", version)
+	return nil
+}
+
+// settings are the prop firm profiles and tracker configuration built from the configuration
+type settings struct {
+	catalogue profiles.Catalogue
+	// defaultProfiles maps a broker type to the profile used by accounts without an assigned profile
+	defaultProfiles map[string]string
+	tracker         jobs.TrackerConfig
+}
+
+// buildSettings builds the profiles and tracker configuration from the configuration, checking they are valid
+func buildSettings(cfg *config.Config) (settings, error) {
+	catalogue, err := profiles.Load(cfg.Profiles.Custom)
+	if err != nil {
+		return settings{}, fmt.Errorf("invalid prop firm profiles: %v", err)
+	}
+
+	// Accounts without an assigned prop firm profile fall back to the default profile of their broker type
+	defaultProfiles := broker.DefaultProfiles()
+	maps.Copy(defaultProfiles, cfg.Profiles.Defaults)
+	for brokerType, name := range defaultProfiles {
+		if _, exists := catalogue[name]; !exists {
+			return settings{}, fmt.Errorf("unknown default profile %s for broker type %s", name, brokerType)
+		}
+	}
+
+	accountRules, err := loadAccountRules(cfg.Rules)
+	if err != nil {
+		return settings{}, err
+	}
+
+	return settings{
+		catalogue:       catalogue,
+		defaultProfiles: defaultProfiles,
+		tracker: jobs.TrackerConfig{
+			CheckInterval:   time.Duration(cfg.Jobs.EquityCheckInterval) * time.Second,
+			FlattenOnBreach: cfg.Jobs.FlattenOnBreach,
+			Sampling: db.SamplingConfig{
+				Interval: time.Duration(cfg.Jobs.EquitySampleInterval) * time.Second,
+				Epsilon:  cfg.Jobs.EquitySampleEpsilon,
+			},
+			MaxConcurrency: cfg.Jobs.EquityCheckConcurrency,
+			WarningLevels:  cfg.Jobs.WarningLevels,
+			AccountRules:   accountRules,
+		},
+	}, nil
+}
+
+// reloadConfig applies the reloaded configuration to the running service once all of it is valid, and notifies what changed.
+// Returns the configuration in effect, which is the current configuration if the reload failed
+func reloadConfig(current, next *config.Config, err error, tracker *jobs.EquityTracker, notifier *notifications.SwitchNotifier) *config.Config {
+	var s settings
+	var channels notifications.Notifier
+	if err == nil {
+		s, err = buildSettings(next)
+	}
+	if err == nil {
+		channels, err = notifications.NewNotifier(next.Notifications)
+	}
+	if err != nil {
+		logger.Errorf("Failed to reload configuration, keeping the current configuration: %v", err)
+		notifier.NotifyError("Failed to reload configuration, keeping the current configuration", err)
+		return current
+	}
+
+	changes := config.Changes(current, next)
+	if len(changes) == 0 {
+		logger.Infof("Configuration reloaded with no changes")
+		return current
+	}
+
+	tracker.Reload(s.catalogue, s.defaultProfiles, s.tracker)
+	notifier.Swap(channels)
+
+	summary := fmt.Sprintf("Configuration reloaded:\n- %s", strings.Join(changes, "\n- "))
+	logger.Infof("%s", summary)
+	notifier.Notify(notifications.SeverityInfo, summary)
+	return next
+}
+
+// loadAccountRules converts the configured account rules, checking each rule is valid
+func loadAccountRules(cfg map[string][]config.RuleConfig) (map[string][]rules.Config, error) {
+	accountRules := make(map[string][]rules.Config, len(cfg))
+	for accountId, configs := range cfg {
+		for _, c := range configs {
+			rc := rules.Config{Type: c.Type, LimitPercent: c.LimitPercent, TrailingMode: c.TrailingMode}
+			if _, err := rules.NewRule(rc); err != nil {
+				return nil, fmt.Errorf("invalid rule for account %s: %v", accountId, err)
+			}
+			accountRules[accountId] = append(accountRules[accountId], rc)
+		}
+	}
+	return accountRules, nil
+}
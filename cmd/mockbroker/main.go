@@ -0,0 +1,54 @@
+// Command mockbroker runs a fake Oanda and MT5 broker locally, for running the risk manager without real broker accounts.
+//
+// Point OANDA_API_URL at http://localhost:<port> and MT5_API_URL at the same address, using the same API key for both.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker/brokertest"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "address to listen on")
+	apiKey := flag.String("api-key", "test", "API key required by both the Oanda and MT5 APIs")
+	accounts := flag.String("accounts", "101-004-0000000-001=100000,12345=100000", "comma separated account=equity path, where the equity path is a list of values separated by '|'")
+	latency := flag.Duration("latency", 0, "delay added to every response")
+	flag.Parse()
+
+	b := brokertest.NewBroker(*apiKey)
+	b.SetLatency(*latency)
+
+	for _, entry := range strings.Split(*accounts, ",") {
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			log.Fatalf("invalid account %q, expected account=equity", entry)
+		}
+
+		var equity []float64
+		for _, v := range strings.Split(path, "|") {
+			e, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				log.Fatalf("invalid equity %q for account %s: %v", v, id, err)
+			}
+			equity = append(equity, e)
+		}
+
+		b.SetEquity(id, equity...)
+		log.Printf("Serving account %s with equity path %v", id, equity)
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           b,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	log.Printf("Mock broker listening on %s", *addr)
+	log.Fatal(server.ListenAndServe())
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+type HaltRequest struct {
+	// Mode is UNTIL_RESET or UNTIL_RELEASED. Defaults to UNTIL_RELEASED
+	Mode   string `json:"mode"`
+	Reason string `json:"reason"`
+}
+
+type HaltResponse struct {
+	AccountId string `json:"accountId"`
+	Halted    bool   `json:"halted"`
+	// Mode, Source, Reason and CreatedAt are omitted if the account is not halted
+	Mode      string     `json:"mode,omitempty"`
+	Source    string     `json:"source,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// HaltController manages the trading halt (kill switch) state of accounts
+type HaltController interface {
+	AccountHalt(ctx context.Context, accountId string) (db.AccountHalt, bool, error)
+	Halt(ctx context.Context, accountId, mode, reason string) (db.AccountHalt, error)
+	Resume(ctx context.Context, accountId string) error
+}
+
+type AccountsHandler struct {
+	halts HaltController
+}
+
+func NewAccountsHandler(halts HaltController) *AccountsHandler {
+	return &AccountsHandler{
+		halts: halts,
+	}
+}
+
+// GetHalt returns the trading halt state of a specified trading account.
+//
+// Path Parameters:
+//   - accountId: The ID of the trading account
+//
+// Returns:
+//   - 200: JSON response with the halt state
+//   - 404: If the account is not an active broker account
+//   - 500: If an internal error occurs
+//
+// Response format:
+//
+//	{
+//	  "accountId": "string",
+//	  "halted": bool,
+//	  "mode": "UNTIL_RESET | UNTIL_RELEASED", (optional)
+//	  "source": "BREACH | MANUAL", (optional)
+//	  "reason": "string", (optional)
+//	  "until": "RFC3339 timestamp", (optional)
+//	  "createdAt": "RFC3339 timestamp" (optional)
+//	}
+func (h *AccountsHandler) GetHalt(w http.ResponseWriter, r *http.Request) {
+	accountId := r.PathValue("accountId")
+
+	halt, halted, err := h.halts.AccountHalt(r.Context(), accountId)
+	if err != nil {
+		writeHaltError(w, err)
+		return
+	}
+
+	writeHalt(w, accountId, halt, halted)
+}
+
+// Halt halts trading on a specified trading account. While halted, orders are denied by the pre-trade check
+// and any positions or orders opened on the account are flattened by the tracker.
+//
+// Path Parameters:
+//   - accountId: The ID of the trading account
+//
+// Request format:
+//
+//	{
+//	  "mode": "UNTIL_RESET | UNTIL_RELEASED", (optional) defaults to UNTIL_RELEASED
+//	  "reason": "string"
+//	}
+//
+// Returns:
+//   - 200: JSON response with the halt state, as for GetHalt
+//   - 400: If the request is invalid
+//   - 404: If the account is not an active broker account
+//   - 500: If an internal error occurs
+func (h *AccountsHandler) Halt(w http.ResponseWriter, r *http.Request) {
+	accountId := r.PathValue("accountId")
+
+	var req HaltRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = db.HaltUntilReleased
+	}
+	if req.Mode != db.HaltUntilReset && req.Mode != db.HaltUntilReleased {
+		http.Error(w, "mode must be one of UNTIL_RESET, UNTIL_RELEASED", http.StatusBadRequest)
+		return
+	}
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+
+	halt, err := h.halts.Halt(r.Context(), accountId, req.Mode, req.Reason)
+	if err != nil {
+		writeHaltError(w, err)
+		return
+	}
+
+	writeHalt(w, accountId, halt, true)
+}
+
+// Resume releases any trading halt of a specified trading account, including halts set on breach.
+//
+// Path Parameters:
+//   - accountId: The ID of the trading account
+//
+// Returns:
+//   - 200: JSON response with the halt state, as for GetHalt
+//   - 404: If the account is not an active broker account
+//   - 500: If an internal error occurs
+func (h *AccountsHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	accountId := r.PathValue("accountId")
+
+	if err := h.halts.Resume(r.Context(), accountId); err != nil {
+		writeHaltError(w, err)
+		return
+	}
+
+	writeHalt(w, accountId, db.AccountHalt{}, false)
+}
+
+func writeHaltError(w http.ResponseWriter, err error) {
+	if errors.Is(err, jobs.ErrAccountNotFound) {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	logger.Errorf("Error managing account halt: %v", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func writeHalt(w http.ResponseWriter, accountId string, halt db.AccountHalt, halted bool) {
+	response := HaltResponse{
+		AccountId: accountId,
+		Halted:    halted,
+	}
+	if halted {
+		response.Mode = halt.Mode
+		response.Source = halt.Source
+		response.Reason = halt.Reason
+		response.CreatedAt = &halt.CreatedAt
+		if !halt.Until.IsZero() {
+			response.Until = &halt.Until
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Errorf("Error encoding response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
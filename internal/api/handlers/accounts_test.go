@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
+)
+
+type fakeHalts struct {
+	halts map[string]db.AccountHalt
+}
+
+func (f *fakeHalts) AccountHalt(_ context.Context, accountId string) (db.AccountHalt, bool, error) {
+	if accountId != "acc-1" {
+		return db.AccountHalt{}, false, jobs.ErrAccountNotFound
+	}
+	h, ok := f.halts[accountId]
+	return h, ok, nil
+}
+
+func (f *fakeHalts) Halt(_ context.Context, accountId, mode, reason string) (db.AccountHalt, error) {
+	if accountId != "acc-1" {
+		return db.AccountHalt{}, jobs.ErrAccountNotFound
+	}
+	h := db.AccountHalt{Mode: mode, Source: db.HaltSourceManual, Reason: reason}
+	f.halts[accountId] = h
+	return h, nil
+}
+
+func (f *fakeHalts) Resume(_ context.Context, accountId string) error {
+	if accountId != "acc-1" {
+		return jobs.ErrAccountNotFound
+	}
+	delete(f.halts, accountId)
+	return nil
+}
+
+func TestHaltAndResume(t *testing.T) {
+	halts := &fakeHalts{halts: map[string]db.AccountHalt{}}
+	h := NewAccountsHandler(halts)
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		accountId  string
+		body       string
+		want       int
+		wantHalted bool
+		wantMode   string
+	}{
+		{name: "Not halted", handler: h.GetHalt, accountId: "acc-1", want: http.StatusOK},
+		{name: "Unknown account", handler: h.GetHalt, accountId: "acc-2", want: http.StatusNotFound},
+		{name: "Invalid body", handler: h.Halt, accountId: "acc-1", body: "{", want: http.StatusBadRequest},
+		{name: "Unsupported mode", handler: h.Halt, accountId: "acc-1", body: `{"mode":"FOREVER","reason":"news"}`, want: http.StatusBadRequest},
+		{name: "Missing reason", handler: h.Halt, accountId: "acc-1", body: `{"mode":"UNTIL_RESET"}`, want: http.StatusBadRequest},
+		{name: "Halt unknown account", handler: h.Halt, accountId: "acc-2", body: `{"reason":"news"}`, want: http.StatusNotFound},
+		{name: "Halt defaults to until released", handler: h.Halt, accountId: "acc-1", body: `{"reason":"news"}`, want: http.StatusOK, wantHalted: true, wantMode: db.HaltUntilReleased},
+		{name: "Halted", handler: h.GetHalt, accountId: "acc-1", want: http.StatusOK, wantHalted: true, wantMode: db.HaltUntilReleased},
+		{name: "Resume", handler: h.Resume, accountId: "acc-1", want: http.StatusOK},
+		{name: "Resumed", handler: h.GetHalt, accountId: "acc-1", want: http.StatusOK},
+	}
+
+	// Cases run in order, as they share the halt state
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/accounts/"+tt.accountId+"/halt", strings.NewReader(tt.body))
+			req.SetPathValue("accountId", tt.accountId)
+			rec := httptest.NewRecorder()
+
+			tt.handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var got HaltResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if got.AccountId != tt.accountId || got.Halted != tt.wantHalted || got.Mode != tt.wantMode {
+				t.Errorf("unexpected response %+v", got)
+			}
+		})
+	}
+}
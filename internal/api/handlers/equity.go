@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -18,6 +19,30 @@ type EquityResponse struct {
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+type EquityHistoryResponse struct {
+	AccountId string `json:"accountId"`
+	Interval  string `json:"interval"`
+	// From and To are the requested range in UTC
+	From time.Time              `json:"from"`
+	To   time.Time              `json:"to"`
+	Data []EquityBucketResponse `json:"data"`
+	// Next is where to request the rest of a raw history range from, omitted if the whole range was returned
+	Next *time.Time `json:"next,omitempty"`
+}
+
+type EquityBucketResponse struct {
+	// Time is the start of the bucket in UTC
+	Time    time.Time `json:"time"`
+	Open    float64   `json:"open"`
+	High    float64   `json:"high"`
+	Low     float64   `json:"low"`
+	Close   float64   `json:"close"`
+	Samples int       `json:"samples"`
+}
+
+// defaultHistoryRange is the range of equity history returned when 'from' is not given
+const defaultHistoryRange = 24 * time.Hour
+
 type EquityHandler struct {
 	dbClient *db.Client
 }
@@ -81,3 +106,121 @@ func (h *EquityHandler) GetLatestEquity(w http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+// GetEquityHistory returns the equity series for a specified trading account, bucketed by interval.
+//
+// Each bucket contains the open, high, low and close of the equity samples recorded within it.
+// With the 'raw' interval every recorded sample is returned as its own bucket, up to 10000 samples. If the range has more,
+// 'next' is set to the time to request the rest of the range from. Other intervals are limited to ranges of 10000 buckets.
+//
+// Query Parameters:
+//   - accountId: (required) The ID of the trading account
+//   - from: (optional) RFC3339 start of the range, inclusive. Defaults to 24 hours before 'to'
+//   - to: (optional) RFC3339 end of the range, exclusive. Defaults to now
+//   - interval: (optional) One of raw, 1m, 1h, 1d. Defaults to 1h
+//
+// Returns:
+//   - 200: JSON response with the equity series, empty if no equity was recorded in the range
+//   - 400: If accountId is missing, from, to or interval are invalid, or the range exceeds 10000 buckets of the interval
+//   - 500: If an internal error occurs
+//
+// Response format:
+//
+//	{
+//	  "accountId": "string",
+//	  "interval": "string",
+//	  "from": "RFC3339 timestamp",
+//	  "to": "RFC3339 timestamp",
+//	  "data": [
+//	    {
+//	      "time": "RFC3339 timestamp",
+//	      "open": float64,
+//	      "high": float64,
+//	      "low": float64,
+//	      "close": float64,
+//	      "samples": int
+//	    }
+//	  ],
+//	  "next": "RFC3339 timestamp"
+//	}
+func (h *EquityHandler) GetEquityHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	accountId := query.Get("accountId")
+	if accountId == "" {
+		http.Error(w, "accountId parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = db.IntervalHour
+	}
+	if !db.IsValidInterval(interval) {
+		http.Error(w, "interval must be one of raw, 1m, 1h, 1d", http.StatusBadRequest)
+		return
+	}
+
+	to := time.Now().UTC()
+	if query.Get("to") != "" {
+		t, err := time.Parse(time.RFC3339, query.Get("to"))
+		if err != nil {
+			http.Error(w, "to must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		to = t.UTC()
+	}
+
+	from := to.Add(-defaultHistoryRange)
+	if query.Get("from") != "" {
+		f, err := time.Parse(time.RFC3339, query.Get("from"))
+		if err != nil {
+			http.Error(w, "from must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		from = f.UTC()
+	}
+
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	if maxRange := db.MaxHistoryRange(interval); maxRange > 0 && to.Sub(from) > maxRange {
+		http.Error(w, fmt.Sprintf("range exceeds %d buckets, the max range of interval %s is %s", db.MaxHistoryBuckets, interval, maxRange), http.StatusBadRequest)
+		return
+	}
+
+	history, err := h.dbClient.GetEquityHistory(r.Context(), accountId, from, to, interval)
+	if err != nil {
+		logger.Errorf("Error getting equity history: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := EquityHistoryResponse{
+		AccountId: accountId,
+		Interval:  interval,
+		From:      from,
+		To:        to,
+		Data:      make([]EquityBucketResponse, 0, len(history.Buckets)),
+		Next:      history.Next,
+	}
+	for _, b := range history.Buckets {
+		response.Data = append(response.Data, EquityBucketResponse{
+			Time:    b.Time,
+			Open:    b.Open,
+			High:    b.High,
+			Low:     b.Low,
+			Close:   b.Close,
+			Samples: b.Samples,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Errorf("Error encoding response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
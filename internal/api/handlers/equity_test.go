@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetEquityHistoryValidation(t *testing.T) {
+	// Validation happens before the database is queried, so no client is required
+	h := NewEquityHandler(nil)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Missing accountId", query: "interval=1h"},
+		{name: "Unsupported interval", query: "accountId=123&interval=5m"},
+		{name: "Invalid from", query: "accountId=123&from=yesterday"},
+		{name: "Invalid to", query: "accountId=123&to=2024-01-01"},
+		{name: "From after to", query: "accountId=123&from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z"},
+		{name: "Range exceeds max minute buckets", query: "accountId=123&interval=1m&from=2024-01-01T00:00:00Z&to=2024-01-08T00:00:00Z"},
+		{name: "Range exceeds max hour buckets", query: "accountId=123&interval=1h&from=2023-01-01T00:00:00Z&to=2024-03-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/equity/history?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.GetEquityHistory(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -1,8 +1,244 @@
 package handlers
 
-import "net/http"
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
 
-func HealthCheck(w http.ResponseWriter, _ *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
+	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// Health statuses, from best to worst
+const (
+	HealthOK = "ok"
+	// HealthDegraded is reported when a dependency is failing but the service can still do its job,
+	// e.g. a broker is unreachable
+	HealthDegraded = "degraded"
+	// HealthUnavailable is reported when the service can't do its job and should be restarted or taken out of service
+	HealthUnavailable = "unavailable"
+)
+
+// dbPingTimeout is the max time allowed for the database to respond to a readiness check
+const dbPingTimeout = 2 * time.Second
+
+type HealthResponse struct {
+	Status string       `json:"status"`
+	Checks HealthChecks `json:"checks"`
+}
+
+type HealthChecks struct {
+	Tracker   TrackerCheck             `json:"tracker"`
+	Database  *Check                   `json:"database,omitempty"`
+	Brokers   map[string]BrokerCheck   `json:"brokers,omitempty"`
+	Notifiers map[string]NotifierCheck `json:"notifiers,omitempty"`
+}
+
+type Check struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type TrackerCheck struct {
+	Status string `json:"status"`
+	// LastTickAt is omitted if no tick has completed yet
+	LastTickAt *time.Time `json:"lastTickAt,omitempty"`
+	// LastSuccessAt is omitted if no tick has succeeded yet
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	// AgeSeconds is the time since the last completed tick, or since the tracker started
+	AgeSeconds        float64 `json:"ageSeconds"`
+	StaleAfterSeconds float64 `json:"staleAfterSeconds"`
+}
+
+type BrokerCheck struct {
+	Status  string `json:"status"`
+	Circuit string `json:"circuit"`
+}
+
+type NotifierCheck struct {
+	Status        string     `json:"status"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastFailureAt *time.Time `json:"lastFailureAt,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// DBPinger checks the database is reachable
+type DBPinger interface {
+	Ping(ctx context.Context) error
+}
+
+// TrackerHealthReporter reports the health of the equity tracker
+type TrackerHealthReporter interface {
+	Health() jobs.TrackerHealth
+}
+
+type HealthHandler struct {
+	db      DBPinger
+	tracker TrackerHealthReporter
+	// notifiers returns the health of each notification channel
+	notifiers func() map[string]notifications.ChannelHealth
+}
+
+func NewHealthHandler(db DBPinger, tracker TrackerHealthReporter) *HealthHandler {
+	return &HealthHandler{
+		db:        db,
+		tracker:   tracker,
+		notifiers: notifications.Health,
+	}
+}
+
+// Live reports whether the equity tracker is still completing ticks, for the orchestrator to restart a wedged service.
+// Ticks that fail count as completed, so a failing dependency is only reported by Ready and does not cause a restart.
+//
+// Returns:
+//   - 200: If the tracker has completed a tick recently
+//   - 503: If the tracker has not completed a tick within 3 check intervals (at least 1 minute)
+//
+// Response format:
+//
+//	{
+//	  "status": "ok | unavailable",
+//	  "checks": {
+//	    "tracker": {
+//	      "status": "ok | unavailable",
+//	      "lastTickAt": "RFC3339 timestamp", (optional)
+//	      "lastSuccessAt": "RFC3339 timestamp", (optional)
+//	      "ageSeconds": float64,
+//	      "staleAfterSeconds": float64
+//	    }
+//	  }
+//	}
+func (h *HealthHandler) Live(w http.ResponseWriter, _ *http.Request) {
+	response := HealthResponse{Checks: HealthChecks{Tracker: trackerCheck(h.tracker.Health())}}
+	response.Status = response.Checks.Tracker.Status
+
+	writeHealth(w, response)
+}
+
+// Ready reports the health of the service and each of its dependencies.
+// The service is unavailable if the tracker is wedged or the database is unreachable,
+// and degraded if the last tick failed, a broker circuit breaker is open or the latest notification to a channel failed.
+//
+// Returns:
+//   - 200: If the service is ok or degraded
+//   - 503: If the service is unavailable
+//
+// Response format:
+//
+//	{
+//	  "status": "ok | degraded | unavailable",
+//	  "checks": {
+//	    "tracker": { as for Live, "status": "ok | degraded | unavailable" },
+//	    "database": { "status": "ok | unavailable", "error": "string" (optional) },
+//	    "brokers": {
+//	      "OANDA": { "status": "ok | degraded", "circuit": "closed | open | half-open" }
+//	    },
+//	    "notifiers": {
+//	      "telegram": {
+//	        "status": "ok | degraded",
+//	        "lastSuccessAt": "RFC3339 timestamp", (optional)
+//	        "lastFailureAt": "RFC3339 timestamp", (optional)
+//	        "error": "string" (optional)
+//	      }
+//	    }
+//	  }
+//	}
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	tracker := h.tracker.Health()
+
+	response := HealthResponse{
+		Status: HealthOK,
+		Checks: HealthChecks{
+			Tracker:   trackerCheck(tracker),
+			Database:  &Check{Status: HealthOK},
+			Brokers:   make(map[string]BrokerCheck),
+			Notifiers: make(map[string]NotifierCheck),
+		},
+	}
+	if response.Checks.Tracker.Status == HealthOK && tracker.Stats.LastTickAt.After(tracker.Stats.LastSuccessAt) {
+		response.Checks.Tracker.Status = HealthDegraded
+	}
+	status := []string{response.Checks.Tracker.Status}
+
+	ctx, cancel := context.WithTimeout(r.Context(), dbPingTimeout)
+	defer cancel()
+	if err := h.db.Ping(ctx); err != nil {
+		logger.Errorf("Database unreachable for readiness check: %v", err)
+		response.Checks.Database = &Check{Status: HealthUnavailable, Error: err.Error()}
+	}
+	status = append(status, response.Checks.Database.Status)
+
+	for brokerType, state := range tracker.Circuits {
+		check := BrokerCheck{Status: HealthOK, Circuit: state.String()}
+		if state == broker.CircuitOpen {
+			check.Status = HealthDegraded
+		}
+		response.Checks.Brokers[brokerType] = check
+		status = append(status, check.Status)
+	}
+
+	for channel, health := range h.notifiers() {
+		check := NotifierCheck{Status: HealthOK, Error: health.LastError}
+		if !health.LastSuccessAt.IsZero() {
+			check.LastSuccessAt = &health.LastSuccessAt
+		}
+		if !health.LastFailureAt.IsZero() {
+			check.LastFailureAt = &health.LastFailureAt
+		}
+		if !health.Healthy() {
+			check.Status = HealthDegraded
+		}
+		response.Checks.Notifiers[channel] = check
+		status = append(status, check.Status)
+	}
+
+	response.Status = worst(status...)
+	writeHealth(w, response)
+}
+
+func trackerCheck(health jobs.TrackerHealth) TrackerCheck {
+	check := TrackerCheck{
+		Status:            HealthOK,
+		AgeSeconds:        health.Age.Seconds(),
+		StaleAfterSeconds: health.StaleAfter.Seconds(),
+	}
+	if !health.Stats.LastTickAt.IsZero() {
+		lastTick := health.Stats.LastTickAt.UTC()
+		check.LastTickAt = &lastTick
+	}
+	if !health.Stats.LastSuccessAt.IsZero() {
+		lastSuccess := health.Stats.LastSuccessAt.UTC()
+		check.LastSuccessAt = &lastSuccess
+	}
+	if !health.Healthy {
+		check.Status = HealthUnavailable
+	}
+	return check
+}
+
+// worst returns the worst of the statuses
+func worst(statuses ...string) string {
+	result := HealthOK
+	for _, s := range statuses {
+		if s == HealthUnavailable {
+			return HealthUnavailable
+		}
+		if s == HealthDegraded {
+			result = HealthDegraded
+		}
+	}
+	return result
+}
+
+func writeHealth(w http.ResponseWriter, response HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status == HealthUnavailable {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.Errorf("Error encoding response: %v", err)
+	}
 }
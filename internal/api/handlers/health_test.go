@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
+	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (f fakePinger) Ping(context.Context) error {
+	return f.err
+}
+
+type fakeHealthReporter struct {
+	health jobs.TrackerHealth
+}
+
+func (f fakeHealthReporter) Health() jobs.TrackerHealth {
+	return f.health
+}
+
+func TestHealth(t *testing.T) {
+	logger.InitLogger()
+	now := time.Now()
+
+	healthy := jobs.TrackerHealth{
+		Stats:      jobs.TickStats{LastTickAt: now, LastSuccessAt: now},
+		Age:        time.Second,
+		StaleAfter: time.Minute,
+		Healthy:    true,
+		Circuits:   map[string]broker.CircuitState{broker.Oanda: broker.CircuitClosed},
+	}
+	wedged := healthy
+	wedged.Age, wedged.Healthy = 5*time.Minute, false
+	tickFailing := healthy
+	tickFailing.Stats = jobs.TickStats{LastTickAt: now, LastSuccessAt: now.Add(-time.Hour)}
+	circuitOpen := healthy
+	circuitOpen.Circuits = map[string]broker.CircuitState{broker.Oanda: broker.CircuitOpen}
+
+	tests := []struct {
+		name      string
+		dbErr     error
+		tracker   jobs.TrackerHealth
+		notifiers map[string]notifications.ChannelHealth
+		wantLive  int
+		wantReady int
+		want      string
+	}{
+		{name: "Healthy", tracker: healthy, wantLive: http.StatusOK, wantReady: http.StatusOK, want: HealthOK},
+		{name: "Tracker wedged", tracker: wedged, wantLive: http.StatusServiceUnavailable, wantReady: http.StatusServiceUnavailable, want: HealthUnavailable},
+		{name: "Database unreachable", dbErr: errors.New("connection refused"), tracker: healthy, wantLive: http.StatusOK, wantReady: http.StatusServiceUnavailable, want: HealthUnavailable},
+		{name: "Last tick failed", dbErr: errors.New("connection refused"), tracker: tickFailing, wantLive: http.StatusOK, wantReady: http.StatusServiceUnavailable, want: HealthUnavailable},
+		{name: "Last tick failed with database reachable", tracker: tickFailing, wantLive: http.StatusOK, wantReady: http.StatusOK, want: HealthDegraded},
+		{name: "Broker circuit open", tracker: circuitOpen, wantLive: http.StatusOK, wantReady: http.StatusOK, want: HealthDegraded},
+		{
+			name:    "Notifier failing",
+			tracker: healthy,
+			notifiers: map[string]notifications.ChannelHealth{
+				notifications.ChannelSlack: {LastSuccessAt: now.Add(-time.Hour), LastFailureAt: now, LastError: "unexpected status 500"},
+			},
+			wantLive:  http.StatusOK,
+			wantReady: http.StatusOK,
+			want:      HealthDegraded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHealthHandler(fakePinger{err: tt.dbErr}, fakeHealthReporter{health: tt.tracker})
+			h.notifiers = func() map[string]notifications.ChannelHealth { return tt.notifiers }
+
+			rec := httptest.NewRecorder()
+			h.Live(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+			if rec.Code != tt.wantLive {
+				t.Errorf("live status = %d, want %d", rec.Code, tt.wantLive)
+			}
+
+			rec = httptest.NewRecorder()
+			h.Ready(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+			if rec.Code != tt.wantReady {
+				t.Errorf("ready status = %d, want %d", rec.Code, tt.wantReady)
+			}
+
+			var got HealthResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if got.Status != tt.want {
+				t.Errorf("ready = %q, want %q: %+v", got.Status, tt.want, got.Checks)
+			}
+			if got.Checks.Database == nil || (tt.dbErr != nil) != (got.Checks.Database.Error != "") {
+				t.Errorf("unexpected database check %+v", got.Checks.Database)
+			}
+			if got.Checks.Brokers[broker.Oanda].Circuit == "" {
+				t.Errorf("expected the %s circuit state, got %+v", broker.Oanda, got.Checks.Brokers)
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+type RiskEventsResponse struct {
+	Events []RiskEventResponse `json:"events"`
+}
+
+type RiskEventResponse struct {
+	ID         int64   `json:"id"`
+	AccountId  string  `json:"accountId"`
+	BrokerName string  `json:"brokerName"`
+	Rule       string  `json:"rule"`
+	Level      string  `json:"level"`
+	Threshold  float64 `json:"threshold"`
+	Limit      float64 `json:"limit"`
+	Equity     float64 `json:"equity"`
+	Action     string  `json:"action"`
+	// WarningLevel is the warning level crossed, as a percentage of the rule's allowed loss, null for breaches
+	WarningLevel *float64 `json:"warningLevel"`
+	// Time is when the rule was evaluated, in UTC
+	Time time.Time `json:"time"`
+}
+
+type RiskStatusResponse struct {
+	AccountId  string  `json:"accountId"`
+	BrokerName string  `json:"brokerName"`
+	Profile    string  `json:"profile"`
+	Timezone   string  `json:"timezone"`
+	Equity     float64 `json:"equity"`
+	// DayStartEquity, DailyPnl and DailyPnlPercent are null until the day start equity has been recorded
+	DayStartEquity      *float64 `json:"dayStartEquity"`
+	DayStartApproximate bool     `json:"dayStartApproximate"`
+	DailyPnl            *float64 `json:"dailyPnl"`
+	DailyPnlPercent     *float64 `json:"dailyPnlPercent"`
+	// DailyLossHeadroom and MaxDrawdownHeadroom are how far equity can drop before a breach, null if the rule is not enabled
+	DailyLossHeadroom   *float64              `json:"dailyLossHeadroom"`
+	MaxDrawdownHeadroom *float64              `json:"maxDrawdownHeadroom"`
+	ProfitTarget        *ProfitTargetResponse `json:"profitTarget"`
+	// MinTradingDays is the minimum number of days the profile requires to be traded to pass, null if there is no minimum
+	MinTradingDays *int                 `json:"minTradingDays"`
+	Rules          []RuleStatusResponse `json:"rules"`
+	// NextReset is the start of the next trading day, in the profile's timezone
+	NextReset time.Time `json:"nextReset"`
+	// UpdatedAt is when the equity was fetched, in UTC
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ProfitTargetResponse struct {
+	Equity          float64 `json:"equity"`
+	ProgressPercent float64 `json:"progressPercent"`
+}
+
+type RuleStatusResponse struct {
+	Rule        string  `json:"rule"`
+	Threshold   float64 `json:"threshold"`
+	Limit       float64 `json:"limit"`
+	Headroom    float64 `json:"headroom"`
+	UsedPercent float64 `json:"usedPercent"`
+	Breached    bool    `json:"breached"`
+}
+
+type CheckOrderRequest struct {
+	AccountId        string  `json:"accountId"`
+	Instrument       string  `json:"instrument"`
+	Units            float64 `json:"units"`
+	StopLossDistance float64 `json:"stopLossDistance"`
+	// ConversionRate converts the quote currency of the instrument to the account currency. Defaults to 1
+	ConversionRate float64 `json:"conversionRate"`
+}
+
+type CheckOrderResponse struct {
+	Allowed    bool    `json:"allowed"`
+	Reason     string  `json:"reason"`
+	AccountId  string  `json:"accountId"`
+	Instrument string  `json:"instrument"`
+	Equity     float64 `json:"equity"`
+	Risk       float64 `json:"risk"`
+	// Rule and Headroom are of the rule closest to being breached, omitted if the account has no rules
+	Rule     string   `json:"rule,omitempty"`
+	Headroom *float64 `json:"headroom,omitempty"`
+}
+
+// RiskTracker provides the live risk state of accounts
+type RiskTracker interface {
+	AccountStatus(accountId string) (jobs.AccountStatus, bool)
+	CheckOrder(ctx context.Context, order jobs.OrderCheck) (jobs.OrderDecision, error)
+}
+
+// defaultRiskEventsLimit is the number of risk events returned when 'limit' is not given
+const defaultRiskEventsLimit = 100
+
+type RiskHandler struct {
+	dbClient *db.Client
+	tracker  RiskTracker
+}
+
+func NewRiskHandler(dbClient *db.Client, tracker RiskTracker) *RiskHandler {
+	return &RiskHandler{
+		dbClient: dbClient,
+		tracker:  tracker,
+	}
+}
+
+// CheckOrder decides whether a proposed order may be placed, based on the live equity of the account
+// and its remaining headroom before any rule is breached. Orders are denied whenever the risk cannot be determined,
+// e.g. if the broker is unreachable.
+//
+// Request format:
+//
+//	{
+//	  "accountId": "string",
+//	  "instrument": "string",
+//	  "units": float64, negative for sells
+//	  "stopLossDistance": float64, price distance between the entry and the stop loss
+//	  "conversionRate": float64, (optional) quote currency to account currency rate. Defaults to 1
+//	}
+//
+// Returns:
+//   - 200: JSON response with the decision, whether allowed or denied
+//   - 400: If the request is invalid
+//   - 404: If the account has not been checked since the service started
+//   - 500: If an internal error occurs
+//
+// Response format:
+//
+//	{
+//	  "allowed": bool,
+//	  "reason": "string",
+//	  "accountId": "string",
+//	  "instrument": "string",
+//	  "equity": float64,
+//	  "risk": float64,
+//	  "rule": "string", (optional)
+//	  "headroom": float64 (optional)
+//	}
+func (h *RiskHandler) CheckOrder(w http.ResponseWriter, r *http.Request) {
+	var req CheckOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.AccountId == "" || req.Instrument == "" {
+		http.Error(w, "accountId and instrument are required", http.StatusBadRequest)
+		return
+	}
+	if req.Units == 0 {
+		http.Error(w, "units must not be 0", http.StatusBadRequest)
+		return
+	}
+	if req.StopLossDistance <= 0 {
+		http.Error(w, "stopLossDistance must be greater than 0", http.StatusBadRequest)
+		return
+	}
+	if req.ConversionRate < 0 {
+		http.Error(w, "conversionRate must be greater than 0", http.StatusBadRequest)
+		return
+	}
+	if req.ConversionRate == 0 {
+		req.ConversionRate = 1
+	}
+
+	decision, err := h.tracker.CheckOrder(r.Context(), jobs.OrderCheck{
+		AccountID:        req.AccountId,
+		Instrument:       req.Instrument,
+		Units:            req.Units,
+		StopLossDistance: req.StopLossDistance,
+		ConversionRate:   req.ConversionRate,
+	})
+	if errors.Is(err, jobs.ErrAccountNotTracked) {
+		http.Error(w, "No risk status found for account", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Errorf("Error checking order: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !decision.Allowed {
+		logger.Infof("Order for %v %s denied for account %s: %s", req.Units, req.Instrument, req.AccountId, decision.Reason)
+	}
+
+	response := CheckOrderResponse{
+		Allowed:    decision.Allowed,
+		Reason:     decision.Reason,
+		AccountId:  req.AccountId,
+		Instrument: req.Instrument,
+		Equity:     decision.Equity,
+		Risk:       decision.Risk,
+		Rule:       decision.Rule,
+	}
+	if decision.Rule != "" {
+		response.Headroom = &decision.Headroom
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Errorf("Error encoding response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetRiskStatus returns the live risk headroom of a specified trading account, as of its last equity check.
+//
+// Callers should check 'updatedAt', as the status is not updated while the broker is unreachable.
+//
+// Query Parameters:
+//   - accountId: (required) The ID of the trading account
+//
+// Returns:
+//   - 200: JSON response with the account's risk status
+//   - 400: If accountId parameter is missing
+//   - 404: If the account has not been checked since the service started
+//
+// Response format:
+//
+//	{
+//	  "accountId": "string",
+//	  "brokerName": "string",
+//	  "profile": "string",
+//	  "timezone": "string",
+//	  "equity": float64,
+//	  "dayStartEquity": float64 | null,
+//	  "dayStartApproximate": bool,
+//	  "dailyPnl": float64 | null,
+//	  "dailyPnlPercent": float64 | null,
+//	  "dailyLossHeadroom": float64 | null,
+//	  "maxDrawdownHeadroom": float64 | null,
+//	  "profitTarget": { "equity": float64, "progressPercent": float64 } | null,
+//	  "minTradingDays": int | null,
+//	  "rules": [
+//	    {
+//	      "rule": "string",
+//	      "threshold": float64,
+//	      "limit": float64,
+//	      "headroom": float64,
+//	      "usedPercent": float64,
+//	      "breached": bool
+//	    }
+//	  ],
+//	  "nextReset": "RFC3339 timestamp in the profile's timezone",
+//	  "updatedAt": "RFC3339 timestamp"
+//	}
+func (h *RiskHandler) GetRiskStatus(w http.ResponseWriter, r *http.Request) {
+	accountId := r.URL.Query().Get("accountId")
+	if accountId == "" {
+		http.Error(w, "accountId parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	status, exists := h.tracker.AccountStatus(accountId)
+	if !exists {
+		http.Error(w, "No risk status found for account", http.StatusNotFound)
+		return
+	}
+
+	response := RiskStatusResponse{
+		AccountId:           status.AccountID,
+		BrokerName:          status.BrokerName,
+		Profile:             status.Profile,
+		Timezone:            status.Timezone,
+		Equity:              status.Equity,
+		DayStartApproximate: status.DayStartApproximate,
+		Rules:               make([]RuleStatusResponse, 0, len(status.Rules)),
+		NextReset:           status.NextReset,
+		UpdatedAt:           status.UpdatedAt.UTC(),
+	}
+
+	if pnl, ok := status.DailyPnL(); ok {
+		pnlPercent := pnl / status.DayStartEquity * 100
+		response.DayStartEquity = &status.DayStartEquity
+		response.DailyPnl = &pnl
+		response.DailyPnlPercent = &pnlPercent
+	}
+	if headroom, ok := status.Headroom(rules.DailyLoss); ok {
+		response.DailyLossHeadroom = &headroom
+	}
+	if headroom, ok := status.Headroom(rules.MaxLoss, rules.TrailingDrawdown); ok {
+		response.MaxDrawdownHeadroom = &headroom
+	}
+	if target, ok := status.ProfitTarget(); ok {
+		progress, _ := status.ProfitTargetProgress()
+		response.ProfitTarget = &ProfitTargetResponse{Equity: target, ProgressPercent: progress}
+	}
+	if status.MinTradingDays > 0 {
+		response.MinTradingDays = &status.MinTradingDays
+	}
+	for _, res := range status.Rules {
+		response.Rules = append(response.Rules, RuleStatusResponse{
+			Rule:        res.Rule,
+			Threshold:   res.Threshold,
+			Limit:       res.Limit,
+			Headroom:    res.Equity - res.Limit,
+			UsedPercent: res.UsedPercent,
+			Breached:    res.Breached,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Errorf("Error encoding response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetRiskEvents returns the audit trail of rule evaluations that crossed a warning level or breach limit, newest first.
+//
+// Query Parameters:
+//   - accountId: (optional) The ID of the trading account
+//   - rule: (optional) The rule type, e.g. daily_loss, max_loss, trailing_drawdown
+//   - level: (optional) WARNING or BREACH
+//   - from: (optional) RFC3339 start of the range, inclusive
+//   - to: (optional) RFC3339 end of the range, exclusive
+//   - limit: (optional) Max number of events returned, up to 1000. Defaults to 100
+//
+// Returns:
+//   - 200: JSON response with the matching events, empty if there are none
+//   - 400: If any of the parameters are invalid
+//   - 500: If an internal error occurs
+//
+// Response format:
+//
+//	{
+//	  "events": [
+//	    {
+//	      "id": int64,
+//	      "accountId": "string",
+//	      "brokerName": "string",
+//	      "rule": "string",
+//	      "level": "string",
+//	      "threshold": float64,
+//	      "limit": float64,
+//	      "equity": float64,
+//	      "action": "string",
+//	      "time": "RFC3339 timestamp"
+//	    }
+//	  ]
+//	}
+func (h *RiskHandler) GetRiskEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := db.RiskEventFilter{
+		AccountID: query.Get("accountId"),
+		Rule:      query.Get("rule"),
+		Level:     query.Get("level"),
+		Limit:     defaultRiskEventsLimit,
+	}
+
+	if filter.Level != "" && filter.Level != db.RiskLevelWarning && filter.Level != db.RiskLevelBreach {
+		http.Error(w, "level must be one of WARNING, BREACH", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("from") != "" {
+		f, err := time.Parse(time.RFC3339, query.Get("from"))
+		if err != nil {
+			http.Error(w, "from must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.From = f.UTC()
+	}
+
+	if query.Get("to") != "" {
+		t, err := time.Parse(time.RFC3339, query.Get("to"))
+		if err != nil {
+			http.Error(w, "to must be an RFC3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		filter.To = t.UTC()
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	if query.Get("limit") != "" {
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 1 || limit > db.MaxRiskEvents {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.dbClient.GetRiskEvents(r.Context(), filter)
+	if err != nil {
+		logger.Errorf("Error getting risk events: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	response := RiskEventsResponse{
+		Events: make([]RiskEventResponse, 0, len(events)),
+	}
+	for _, e := range events {
+		response.Events = append(response.Events, riskEventResponse(e))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		logger.Errorf("Error encoding response: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// riskEventResponse maps a stored risk event to its API response
+func riskEventResponse(e db.RiskEvent) RiskEventResponse {
+	response := RiskEventResponse{
+		ID:         e.ID,
+		AccountId:  e.AccountID,
+		BrokerName: e.BrokerName,
+		Rule:       e.Rule,
+		Level:      e.Level,
+		Threshold:  e.Threshold,
+		Limit:      e.Limit,
+		Equity:     e.Equity,
+		Action:     e.Action,
+		Time:       e.CreatedAt,
+	}
+	if e.Level == db.RiskLevelWarning {
+		level := e.WarningLevel
+		response.WarningLevel = &level
+	}
+	return response
+}
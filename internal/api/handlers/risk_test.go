@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+func TestGetRiskEventsValidation(t *testing.T) {
+	// Validation happens before the database is queried, so no client is required
+	h := NewRiskHandler(nil, nil)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Unsupported level", query: "level=CRITICAL"},
+		{name: "Invalid from", query: "from=yesterday"},
+		{name: "Invalid to", query: "to=2024-01-01"},
+		{name: "From after to", query: "from=2024-01-02T00:00:00Z&to=2024-01-01T00:00:00Z"},
+		{name: "Invalid limit", query: "limit=ten"},
+		{name: "Limit too large", query: "limit=1001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/risk/events?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.GetRiskEvents(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+type fakeTracker struct {
+	statuses map[string]jobs.AccountStatus
+	decision jobs.OrderDecision
+	// orders are the order checks received
+	orders []jobs.OrderCheck
+}
+
+func (f *fakeTracker) AccountStatus(accountId string) (jobs.AccountStatus, bool) {
+	s, ok := f.statuses[accountId]
+	return s, ok
+}
+
+func (f *fakeTracker) CheckOrder(_ context.Context, order jobs.OrderCheck) (jobs.OrderDecision, error) {
+	if _, ok := f.statuses[order.AccountID]; !ok {
+		return jobs.OrderDecision{}, jobs.ErrAccountNotTracked
+	}
+	f.orders = append(f.orders, order)
+	return f.decision, nil
+}
+
+func TestGetRiskStatus(t *testing.T) {
+	london, _ := time.LoadLocation("Europe/London")
+	h := NewRiskHandler(nil, &fakeTracker{statuses: map[string]jobs.AccountStatus{
+		"acc-1": {
+			AccountID:           "acc-1",
+			BrokerName:          "Oanda",
+			Profile:             "ftmo",
+			Timezone:            "Europe/London",
+			Equity:              10100,
+			DayStartEquity:      10200,
+			InitialBalance:      10000,
+			ProfitTargetPercent: 10,
+			MinTradingDays:      4,
+			Rules: []rules.Result{
+				{Rule: rules.DailyLoss, Threshold: 5, Limit: 9690, Equity: 10100},
+				{Rule: rules.MaxLoss, Threshold: 10, Limit: 9000, Equity: 10100},
+			},
+			NextReset: time.Date(2024, 7, 2, 0, 0, 0, 0, london),
+			UpdatedAt: time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC),
+		},
+	}})
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "Missing accountId", query: "", want: http.StatusBadRequest},
+		{name: "Unknown account", query: "accountId=acc-2", want: http.StatusNotFound},
+		{name: "Known account", query: "accountId=acc-1", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/risk/status?"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			h.GetRiskStatus(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			body := rec.Body.String()
+			var got RiskStatusResponse
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if got.DailyPnl == nil || *got.DailyPnl != -100 {
+				t.Errorf("dailyPnl = %v, want -100", got.DailyPnl)
+			}
+			if got.DailyLossHeadroom == nil || *got.DailyLossHeadroom != 410 {
+				t.Errorf("dailyLossHeadroom = %v, want 410", got.DailyLossHeadroom)
+			}
+			if got.MaxDrawdownHeadroom == nil || *got.MaxDrawdownHeadroom != 1100 {
+				t.Errorf("maxDrawdownHeadroom = %v, want 1100", got.MaxDrawdownHeadroom)
+			}
+			if got.ProfitTarget == nil || math.Abs(got.ProfitTarget.ProgressPercent-10) > 1e-9 {
+				t.Errorf("profitTarget = %+v, want 10%% progress", got.ProfitTarget)
+			}
+			if got.MinTradingDays == nil || *got.MinTradingDays != 4 {
+				t.Errorf("minTradingDays = %v, want 4", got.MinTradingDays)
+			}
+			if !strings.Contains(body, `"nextReset":"2024-07-02T00:00:00+01:00"`) {
+				t.Errorf("nextReset not in the account timezone: %s", body)
+			}
+		})
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	logger.InitLogger()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "Invalid body", body: "{", want: http.StatusBadRequest},
+		{name: "Missing instrument", body: `{"accountId":"acc-1","units":1000,"stopLossDistance":0.001}`, want: http.StatusBadRequest},
+		{name: "Zero units", body: `{"accountId":"acc-1","instrument":"EUR_USD","stopLossDistance":0.001}`, want: http.StatusBadRequest},
+		{name: "Missing stop loss", body: `{"accountId":"acc-1","instrument":"EUR_USD","units":1000}`, want: http.StatusBadRequest},
+		{name: "Negative conversion rate", body: `{"accountId":"acc-1","instrument":"EUR_USD","units":1000,"stopLossDistance":0.001,"conversionRate":-1}`, want: http.StatusBadRequest},
+		{name: "Unknown account", body: `{"accountId":"acc-2","instrument":"EUR_USD","units":1000,"stopLossDistance":0.001}`, want: http.StatusNotFound},
+		{name: "Valid order", body: `{"accountId":"acc-1","instrument":"EUR_USD","units":-1000,"stopLossDistance":0.001}`, want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &fakeTracker{
+				statuses: map[string]jobs.AccountStatus{"acc-1": {AccountID: "acc-1"}},
+				decision: jobs.OrderDecision{Reason: "Order risk 1.00 exceeds the remaining headroom 0.50 of rule 'daily_loss'", Risk: 1, Rule: rules.DailyLoss, Headroom: 0.5},
+			}
+			h := NewRiskHandler(nil, tracker)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/risk/check-order", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			h.CheckOrder(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var got CheckOrderResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if got.Allowed || got.Rule != rules.DailyLoss || got.Headroom == nil || *got.Headroom != 0.5 {
+				t.Errorf("unexpected response %+v", got)
+			}
+			if len(tracker.orders) != 1 || tracker.orders[0].ConversionRate != 1 {
+				t.Errorf("expected conversion rate to default to 1, got %+v", tracker.orders)
+			}
+		})
+	}
+}
+
+func TestRiskEventResponseWarningLevel(t *testing.T) {
+	tests := []struct {
+		name  string
+		event db.RiskEvent
+		want  string
+	}{
+		{
+			name:  "Warning",
+			event: db.RiskEvent{Level: db.RiskLevelWarning, WarningLevel: 75, Action: db.ActionNotified},
+			want:  `"warningLevel":75`,
+		},
+		{
+			name:  "Breach",
+			event: db.RiskEvent{Level: db.RiskLevelBreach, Action: db.ActionFlattened},
+			want:  `"warningLevel":null`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(riskEventResponse(tt.event))
+			if err != nil {
+				t.Fatalf("failed to marshal response: %v", err)
+			}
+			if !strings.Contains(string(body), tt.want) {
+				t.Errorf("response = %s, want it to contain %s", body, tt.want)
+			}
+		})
+	}
+}
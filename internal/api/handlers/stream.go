@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/events"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// streamBuffer is the number of events buffered per client before events are dropped for it
+const streamBuffer = 64
+
+// streamHeartbeatInterval is how often a comment is sent on idle streams, so proxies keep the connection open
+const streamHeartbeatInterval = 15 * time.Second
+
+type StreamHandler struct {
+	hub *events.Hub
+}
+
+func NewStreamHandler(hub *events.Hub) *StreamHandler {
+	return &StreamHandler{
+		hub: hub,
+	}
+}
+
+// Stream pushes live equity and risk updates as Server-Sent Events, until the client disconnects.
+// Events are dropped for clients that do not keep up, rather than slowing the equity checks.
+//
+// Query Parameters:
+//   - accountId: (optional) Only stream events of the trading account
+//
+// Returns:
+//   - 200: text/event-stream of events
+//   - 500: If the response cannot be streamed
+//
+// Event format:
+//
+//	id: int64, increasing with every event
+//	event: equity | warning | breach
+//	data: {
+//	  "accountId": "string",
+//	  "brokerName": "string",
+//	  "equity": float64,
+//	  "time": "RFC3339 timestamp",
+//	  (warning and breach only)
+//	  "rule": "string",
+//	  "level": float64, 100 for breaches
+//	  "threshold": float64,
+//	  "limit": float64
+//	}
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	accountId := r.URL.Query().Get("accountId")
+
+	stream, unsubscribe := h.hub.Subscribe(streamBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, open := <-stream:
+			if !open {
+				// The hub is closed when the service shuts down
+				return
+			}
+			if accountId != "" && event.AccountID != accountId {
+				continue
+			}
+
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				logger.Errorf("Error encoding %s event: %v", event.Type, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
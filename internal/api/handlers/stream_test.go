@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/events"
+)
+
+func TestStream(t *testing.T) {
+	hub := events.NewHub()
+	server := httptest.NewServer(http.HandlerFunc(NewStreamHandler(hub).Stream))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?accountId=123", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %s, want text/event-stream", ct)
+	}
+
+	// Headers are flushed once subscribed, so events published from now are streamed
+	hub.Publish(events.TypeEquity, "456", events.EquityUpdate{AccountID: "456", Equity: 1})
+	hub.Publish(events.TypeBreach, "123", events.RiskUpdate{AccountID: "123", Rule: "daily_loss", Level: 100})
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("error reading stream: %v", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if lines[0] != "id: 2" || lines[1] != "event: breach" || !strings.HasPrefix(lines[2], `data: {"accountId":"123","brokerName":"","rule":"daily_loss","level":100`) {
+		t.Errorf("unexpected event %q", lines)
+	}
+
+	// Closing the hub ends the stream
+	hub.Close()
+	if rest, err := io.ReadAll(reader); err != nil || strings.TrimSpace(string(rest)) != "" {
+		t.Errorf("expected stream to end when the hub is closed, got %q, %v", rest, err)
+	}
+}
@@ -9,7 +9,10 @@ import (
 	"github.com/jwtly10/at4j-risk-manager/internal/api/middleware"
 	"github.com/jwtly10/at4j-risk-manager/internal/config"
 	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/events"
+	"github.com/jwtly10/at4j-risk-manager/internal/jobs"
 	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Server struct {
@@ -17,14 +20,31 @@ type Server struct {
 	httpServer *http.Server
 }
 
-func NewServer(cfg *config.Config, dbClient *db.Client) *Server {
+func NewServer(cfg *config.Config, dbClient *db.Client, tracker *jobs.EquityTracker, hub *events.Hub) *Server {
 	equityHandler := handlers.NewEquityHandler(dbClient)
+	riskHandler := handlers.NewRiskHandler(dbClient, tracker)
+	accountsHandler := handlers.NewAccountsHandler(tracker)
+	streamHandler := handlers.NewStreamHandler(hub)
+	healthHandler := handlers.NewHealthHandler(dbClient, tracker)
 
 	mux := http.NewServeMux()
 
 	auth := middleware.APIKeyAuth(cfg.ApiKey)
 	mux.HandleFunc("/api/v1/equity/latest", auth(equityHandler.GetLatestEquity))
-	mux.HandleFunc("/health", handlers.HealthCheck)
+	mux.HandleFunc("/api/v1/equity/history", auth(equityHandler.GetEquityHistory))
+	mux.HandleFunc("/api/v1/risk/events", auth(riskHandler.GetRiskEvents))
+	mux.HandleFunc("/api/v1/risk/status", auth(riskHandler.GetRiskStatus))
+	mux.HandleFunc("POST /api/v1/risk/check-order", auth(riskHandler.CheckOrder))
+	mux.HandleFunc("GET /api/v1/accounts/{accountId}/halt", auth(accountsHandler.GetHalt))
+	mux.HandleFunc("POST /api/v1/accounts/{accountId}/halt", auth(accountsHandler.Halt))
+	mux.HandleFunc("POST /api/v1/accounts/{accountId}/resume", auth(accountsHandler.Resume))
+	mux.HandleFunc("GET /api/v1/stream", auth(streamHandler.Stream))
+	mux.HandleFunc("GET /health/live", healthHandler.Live)
+	mux.HandleFunc("GET /health/ready", healthHandler.Ready)
+	// Kept for existing probes, /health/live should be used instead
+	mux.HandleFunc("GET /health", healthHandler.Live)
+	// Scraped by Prometheus, so not protected by the API key
+	mux.Handle("GET /metrics", promhttp.Handler())
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", cfg.Port),
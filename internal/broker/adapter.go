@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
 	"io"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // Supported broker types
@@ -22,47 +25,90 @@ const (
 type BrokerAdapter interface {
 	// GetEquity returns the current equity of the broker account
 	GetEquity(ctx context.Context, accountId string) (float64, error)
+	// GetOpenPositions returns all open positions of the broker account
+	GetOpenPositions(ctx context.Context, accountId string) ([]Position, error)
+	// GetPendingOrders returns all pending orders of the broker account
+	GetPendingOrders(ctx context.Context, accountId string) ([]Order, error)
+	// CloseAllPositions closes all open positions of the broker account
+	CloseAllPositions(ctx context.Context, accountId string) error
+	// CancelAllOrders cancels all pending orders of the broker account
+	CancelAllOrders(ctx context.Context, accountId string) error
 }
 
 type OandaAdapter struct {
-	client  *http.Client
+	client  *resilientClient
 	apiKey  string
 	baseURL string
 }
 
 type MT5Adapter struct {
-	client  *http.Client
+	client  *resilientClient
 	apiKey  string
 	baseURL string
 }
 
-// NewAdapter is a factory function that returns a new broker adapter based on the given configuration
+func init() {
+	// Every connection to Oanda and the MT5 bridge needs an API key and URL
+	schema := []Field{{Name: FieldApiKey, Required: true}, {Name: FieldBaseUrl, Required: true}}
+
+	Register(Registration{
+		Type:           Oanda,
+		Name:           config.BrokerOanda,
+		Schema:         schema,
+		DefaultProfile: profiles.Default,
+		New: func(client *http.Client, conn config.ConnectionConfig) (BrokerAdapter, error) {
+			return newOandaAdapter(conn.Name, client, conn.ApiKey, conn.BaseUrl), nil
+		},
+	})
+	Register(Registration{
+		Type:           MT5FTMO,
+		Name:           config.BrokerMT5,
+		Schema:         schema,
+		DefaultProfile: profiles.FTMO,
+		New: func(client *http.Client, conn config.ConnectionConfig) (BrokerAdapter, error) {
+			return newMT5Adapter(conn.Name, client, conn.ApiKey, conn.BaseUrl), nil
+		},
+	})
+}
+
+// NewAdapter is a factory function that returns a new broker adapter of the broker type, based on its single connection configuration
 func NewAdapter(client *http.Client, brokerType string, config config.BrokersConfig) (BrokerAdapter, error) {
-	switch brokerType {
-	case Oanda:
-		return newOandaAdapter(client, config.Oanda), nil
-	case MT5FTMO:
-		return newMT5Adapter(client, config.MT5), nil
-	default:
-		return nil, fmt.Errorf("unsupported broker type: %s", brokerType)
+	r, exists := Lookup(brokerType)
+	if !exists || r.Name == "" {
+		return nil, fmt.Errorf("no single connection configuration for broker type: %s", brokerType)
 	}
+	return NewConnectionAdapter(client, singleConnection(r, config))
 }
 
-// newOandaAdapter returns a new Oanda adapter based on the given configuration
-func newOandaAdapter(client *http.Client, config config.OandaConfig) *OandaAdapter {
+// NewConnectionAdapter is a factory function that returns a new broker adapter for a named broker connection,
+// using the factory registered for its broker type. Every setting of the broker type's schema can be set by its environment variable
+func NewConnectionAdapter(client *http.Client, conn config.ConnectionConfig) (BrokerAdapter, error) {
+	r, exists := Lookup(conn.Type)
+	if !exists {
+		return nil, fmt.Errorf("unsupported broker type: %s", conn.Type)
+	}
+	conn = conn.WithEnv(r.settings())
+	if err := r.validate(conn); err != nil {
+		return nil, err
+	}
+	return r.New(client, conn)
+}
+
+// newOandaAdapter returns a new Oanda adapter. name identifies the connection in metrics
+func newOandaAdapter(name string, client *http.Client, apiKey, baseURL string) *OandaAdapter {
 	return &OandaAdapter{
-		client:  client,
-		apiKey:  config.ApiKey,
-		baseURL: config.BaseUrl,
+		client:  newResilientClient(name, client),
+		apiKey:  apiKey,
+		baseURL: baseURL,
 	}
 }
 
-// newMT5Adapter returns a new MT5 adapter based on the given configuration
-func newMT5Adapter(client *http.Client, config config.MT5Config) *MT5Adapter {
+// newMT5Adapter returns a new MT5 adapter. name identifies the connection in metrics
+func newMT5Adapter(name string, client *http.Client, apiKey, baseURL string) *MT5Adapter {
 	return &MT5Adapter{
-		client:  client,
-		apiKey:  config.ApiKey,
-		baseURL: config.BaseUrl,
+		client:  newResilientClient(name, client),
+		apiKey:  apiKey,
+		baseURL: baseURL,
 	}
 }
 
@@ -76,10 +122,56 @@ type OandaAccount struct {
 	Equity string `json:"NAV"`
 }
 
+type OandaPositionsResponse struct {
+	Positions []OandaPosition `json:"positions"`
+}
+
+type OandaPosition struct {
+	Instrument   string            `json:"instrument"`
+	Long         OandaPositionSide `json:"long"`
+	Short        OandaPositionSide `json:"short"`
+	UnrealizedPL string            `json:"unrealizedPL"`
+}
+
+type OandaPositionSide struct {
+	Units string `json:"units"`
+}
+
+type OandaPositionCloseRequest struct {
+	LongUnits  string `json:"longUnits,omitempty"`
+	ShortUnits string `json:"shortUnits,omitempty"`
+}
+
+type OandaOrdersResponse struct {
+	Orders []OandaOrder `json:"orders"`
+}
+
+type OandaOrder struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Instrument string `json:"instrument"`
+	Units      string `json:"units"`
+}
+
 type MT5AccountResponse struct {
 	Equity float64 `json:"equity"`
 }
 
+type MT5Position struct {
+	Ticket int64   `json:"ticket"`
+	Symbol string  `json:"symbol"`
+	Type   string  `json:"type"`
+	Volume float64 `json:"volume"`
+	Profit float64 `json:"profit"`
+}
+
+type MT5Order struct {
+	Ticket int64   `json:"ticket"`
+	Symbol string  `json:"symbol"`
+	Type   string  `json:"type"`
+	Volume float64 `json:"volume"`
+}
+
 func (o *OandaAdapter) GetEquity(ctx context.Context, accountId string) (float64, error) {
 	url := o.baseURL + "/v3/accounts/" + accountId
 
@@ -101,6 +193,126 @@ func (o *OandaAdapter) GetEquity(ctx context.Context, accountId string) (float64
 	return equity, nil
 }
 
+func (o *OandaAdapter) CircuitState() CircuitState {
+	return o.client.breaker.State()
+}
+
+func (o *OandaAdapter) headers() map[string]string {
+	return map[string]string{
+		"Authorization": "Bearer " + o.apiKey,
+	}
+}
+
+func (o *OandaAdapter) GetOpenPositions(ctx context.Context, accountId string) ([]Position, error) {
+	url := o.baseURL + "/v3/accounts/" + accountId + "/openPositions"
+
+	response, err := makeGET[OandaPositionsResponse](ctx, o.client, url, o.headers())
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]Position, 0, len(response.Positions))
+	for _, p := range response.Positions {
+		// Oanda reports long and short units separately, with short units negative
+		long, err := parseOptionalFloat(p.Long.Units)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing long units for %s: %v", p.Instrument, err)
+		}
+		short, err := parseOptionalFloat(p.Short.Units)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing short units for %s: %v", p.Instrument, err)
+		}
+		pl, err := parseOptionalFloat(p.UnrealizedPL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing unrealized P&L for %s: %v", p.Instrument, err)
+		}
+
+		positions = append(positions, Position{
+			ID:           p.Instrument,
+			Instrument:   p.Instrument,
+			Units:        long + short,
+			UnrealizedPL: pl,
+		})
+	}
+
+	return positions, nil
+}
+
+func (o *OandaAdapter) GetPendingOrders(ctx context.Context, accountId string) ([]Order, error) {
+	url := o.baseURL + "/v3/accounts/" + accountId + "/pendingOrders"
+
+	response, err := makeGET[OandaOrdersResponse](ctx, o.client, url, o.headers())
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]Order, 0, len(response.Orders))
+	for _, order := range response.Orders {
+		// Dependent orders such as stop losses have no units
+		units, err := parseOptionalFloat(order.Units)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing units for order %s: %v", order.ID, err)
+		}
+
+		orders = append(orders, Order{
+			ID:         order.ID,
+			Instrument: order.Instrument,
+			Type:       order.Type,
+			Units:      units,
+		})
+	}
+
+	return orders, nil
+}
+
+func (o *OandaAdapter) CloseAllPositions(ctx context.Context, accountId string) error {
+	url := o.baseURL + "/v3/accounts/" + accountId + "/openPositions"
+
+	response, err := makeGET[OandaPositionsResponse](ctx, o.client, url, o.headers())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, p := range response.Positions {
+		// Oanda rejects closing a side of the position that has no units
+		body := OandaPositionCloseRequest{}
+		if p.Long.Units != "" && p.Long.Units != "0" {
+			body.LongUnits = "ALL"
+		}
+		if p.Short.Units != "" && p.Short.Units != "0" {
+			body.ShortUnits = "ALL"
+		}
+		if body.LongUnits == "" && body.ShortUnits == "" {
+			continue
+		}
+
+		closeUrl := o.baseURL + "/v3/accounts/" + accountId + "/positions/" + p.Instrument + "/close"
+		if _, err := makeRequest[json.RawMessage](ctx, o.client, http.MethodPut, closeUrl, o.headers(), body); err != nil {
+			errs = append(errs, fmt.Errorf("error closing position %s: %v", p.Instrument, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (o *OandaAdapter) CancelAllOrders(ctx context.Context, accountId string) error {
+	orders, err := o.GetPendingOrders(ctx, accountId)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, order := range orders {
+		cancelUrl := o.baseURL + "/v3/accounts/" + accountId + "/orders/" + order.ID + "/cancel"
+		if _, err := makeRequest[json.RawMessage](ctx, o.client, http.MethodPut, cancelUrl, o.headers(), nil); err != nil {
+			errs = append(errs, fmt.Errorf("error cancelling order %s: %v", order.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (m *MT5Adapter) GetEquity(ctx context.Context, accountId string) (float64, error) {
 	url := m.baseURL + "/accounts/" + accountId
 
@@ -116,9 +328,161 @@ func (m *MT5Adapter) GetEquity(ctx context.Context, accountId string) (float64,
 	return response.Equity, nil
 }
 
+func (m *MT5Adapter) CircuitState() CircuitState {
+	return m.client.breaker.State()
+}
+
+func (m *MT5Adapter) headers() map[string]string {
+	return map[string]string{
+		"x-api-key": m.apiKey,
+	}
+}
+
+func (m *MT5Adapter) GetOpenPositions(ctx context.Context, accountId string) ([]Position, error) {
+	url := m.baseURL + "/accounts/" + accountId + "/positions"
+
+	response, err := makeGET[[]MT5Position](ctx, m.client, url, m.headers())
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]Position, 0, len(*response))
+	for _, p := range *response {
+		units := p.Volume
+		if p.Type == "SELL" {
+			units = -units
+		}
+
+		positions = append(positions, Position{
+			ID:           strconv.FormatInt(p.Ticket, 10),
+			Instrument:   p.Symbol,
+			Units:        units,
+			UnrealizedPL: p.Profit,
+		})
+	}
+
+	return positions, nil
+}
+
+func (m *MT5Adapter) GetPendingOrders(ctx context.Context, accountId string) ([]Order, error) {
+	url := m.baseURL + "/accounts/" + accountId + "/orders"
+
+	response, err := makeGET[[]MT5Order](ctx, m.client, url, m.headers())
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]Order, 0, len(*response))
+	for _, o := range *response {
+		orders = append(orders, Order{
+			ID:         strconv.FormatInt(o.Ticket, 10),
+			Instrument: o.Symbol,
+			Type:       o.Type,
+			Units:      o.Volume,
+		})
+	}
+
+	return orders, nil
+}
+
+func (m *MT5Adapter) CloseAllPositions(ctx context.Context, accountId string) error {
+	positions, err := m.GetOpenPositions(ctx, accountId)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, p := range positions {
+		closeUrl := m.baseURL + "/accounts/" + accountId + "/positions/" + p.ID + "/close"
+		if _, err := makeRequest[json.RawMessage](ctx, m.client, http.MethodPost, closeUrl, m.headers(), nil); err != nil {
+			errs = append(errs, fmt.Errorf("error closing position %s: %v", p.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *MT5Adapter) CancelAllOrders(ctx context.Context, accountId string) error {
+	orders, err := m.GetPendingOrders(ctx, accountId)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, o := range orders {
+		cancelUrl := m.baseURL + "/accounts/" + accountId + "/orders/" + o.ID
+		if _, err := makeRequest[json.RawMessage](ctx, m.client, http.MethodDelete, cancelUrl, m.headers(), nil); err != nil {
+			errs = append(errs, fmt.Errorf("error cancelling order %s: %v", o.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Flatten cancels all pending orders and then closes all open positions of the broker account.
+// Orders are cancelled first so that nothing can be filled after positions are closed
+func Flatten(ctx context.Context, adapter BrokerAdapter, accountId string) error {
+	var errs []error
+	if err := adapter.CancelAllOrders(ctx, accountId); err != nil {
+		errs = append(errs, fmt.Errorf("error cancelling orders: %w", err))
+	}
+	if err := adapter.CloseAllPositions(ctx, accountId); err != nil {
+		errs = append(errs, fmt.Errorf("error closing positions: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// parseOptionalFloat parses a decimal string returned by a broker, treating an empty value as 0
+func parseOptionalFloat(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
 // makeGET is a helper function to handle GET requests, and resolve generic response types & errors
-func makeGET[T any](ctx context.Context, client *http.Client, url string, headers map[string]string) (*T, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+func makeGET[T any](ctx context.Context, client *resilientClient, url string, headers map[string]string) (*T, error) {
+	return makeRequest[T](ctx, client, http.MethodGet, url, headers, nil)
+}
+
+// makeRequest is a helper function to handle requests with an optional JSON body, and resolve generic response types & errors.
+// Requests are retried and guarded by the circuit breaker of the client
+func makeRequest[T any](ctx context.Context, client *resilientClient, method, url string, headers map[string]string, body any) (*T, error) {
+	var bodyBytes []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling request body: %v", err)
+		}
+		bodyBytes = b
+	}
+
+	respBody, err := client.do(ctx, method, func() ([]byte, error) {
+		return send(ctx, client.client, method, url, headers, bodyBytes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result T
+	if len(respBody) == 0 {
+		return &result, nil
+	}
+	if err := json.NewDecoder(bytes.NewReader(respBody)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+
+	return &result, nil
+}
+
+// send makes a single request attempt, returning the response body or a *statusError on any non 2xx response
+func send(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
@@ -126,6 +490,9 @@ func makeGET[T any](ctx context.Context, client *http.Client, url string, header
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	r, err := client.Do(req)
 	if err != nil {
@@ -133,19 +500,18 @@ func makeGET[T any](ctx context.Context, client *http.Client, url string, header
 	}
 	defer r.Body.Close()
 
-	body, err := io.ReadAll(r.Body)
+	respBody, err := io.ReadAll(r.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error reading response body: %v", err)
 	}
 
-	if r.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d, body: %s", r.StatusCode, string(body))
-	}
-
-	var result T
-	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&result); err != nil {
-		return nil, fmt.Errorf("error decoding response: %v", err)
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return nil, &statusError{
+			statusCode: r.StatusCode,
+			body:       string(respBody),
+			retryAfter: parseRetryAfter(r.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
-	return &result, nil
-}
\ No newline at end of file
+	return respBody, nil
+}
@@ -0,0 +1,170 @@
+package broker_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/broker/brokertest"
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+)
+
+const (
+	testApiKey       = "test-key"
+	testOandaAccount = "101-004-0000000-001"
+	testMT5Account   = "12345"
+)
+
+// newMockAdapter returns an adapter of the broker type connected to the mock broker with the API key
+func newMockAdapter(t *testing.T, server *brokertest.Server, brokerType, apiKey string) broker.BrokerAdapter {
+	t.Helper()
+
+	single := config.SingleConnectionConfig{ApiKey: apiKey, BaseUrl: server.URL}
+	adapter, err := broker.NewAdapter(server.Client(), brokerType, config.BrokersConfig{
+		Single: map[string]config.SingleConnectionConfig{config.BrokerOanda: single, config.BrokerMT5: single},
+	})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+	return adapter
+}
+
+func TestGetEquityMock(t *testing.T) {
+	server := brokertest.NewServer(testApiKey)
+	defer server.Close()
+
+	server.SetEquity(testOandaAccount, 100000.5, 99000.25)
+	server.SetEquity(testMT5Account, 50000)
+
+	tests := []struct {
+		name       string
+		brokerType string
+		accountId  string
+		apiKey     string
+		expected   []float64
+		wantErr    bool
+	}{
+		{name: "Oanda follows equity path", brokerType: broker.Oanda, accountId: testOandaAccount, apiKey: testApiKey, expected: []float64{100000.5, 99000.25, 99000.25}},
+		{name: "MT5", brokerType: broker.MT5FTMO, accountId: testMT5Account, apiKey: testApiKey, expected: []float64{50000}},
+		{name: "Oanda rejects invalid API key", brokerType: broker.Oanda, accountId: testOandaAccount, apiKey: "wrong", wantErr: true},
+		{name: "MT5 rejects invalid API key", brokerType: broker.MT5FTMO, accountId: testMT5Account, apiKey: "wrong", wantErr: true},
+		{name: "Unknown account", brokerType: broker.MT5FTMO, accountId: "999", apiKey: testApiKey, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newMockAdapter(t, server, tt.brokerType, tt.apiKey)
+
+			if tt.wantErr {
+				if _, err := adapter.GetEquity(context.Background(), tt.accountId); err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+
+			for _, expected := range tt.expected {
+				e, err := adapter.GetEquity(context.Background(), tt.accountId)
+				if err != nil {
+					t.Fatalf("failed to get equity: %v", err)
+				}
+				if e != expected {
+					t.Errorf("equity = %.2f, want %.2f", e, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEquityMockRetriesFaults(t *testing.T) {
+	server := brokertest.NewServer(testApiKey)
+	defer server.Close()
+
+	server.SetEquity(testOandaAccount, 100000)
+	server.FailNext(1, brokertest.Fault{Status: http.StatusTooManyRequests, Body: `{"errorMessage":"Rate limit exceeded"}`})
+
+	adapter := newMockAdapter(t, server, broker.Oanda, testApiKey)
+
+	e, err := adapter.GetEquity(context.Background(), testOandaAccount)
+	if err != nil {
+		t.Fatalf("expected rate limited request to be retried, got %v", err)
+	}
+	if e != 100000 {
+		t.Errorf("equity = %.2f, want 100000", e)
+	}
+	if n := server.Requests("GET /v3/accounts/{id}"); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestFlattenMock(t *testing.T) {
+	tests := []struct {
+		name       string
+		brokerType string
+		accountId  string
+		positions  []broker.Position
+		orders     []broker.Order
+	}{
+		{
+			name:       "Oanda",
+			brokerType: broker.Oanda,
+			accountId:  testOandaAccount,
+			positions: []broker.Position{
+				{ID: "EUR_USD", Instrument: "EUR_USD", Units: 1000, UnrealizedPL: 12.5},
+				{ID: "GBP_USD", Instrument: "GBP_USD", Units: -2000, UnrealizedPL: -3},
+			},
+			orders: []broker.Order{{ID: "42", Instrument: "EUR_USD", Type: "LIMIT", Units: 1000}},
+		},
+		{
+			name:       "MT5",
+			brokerType: broker.MT5FTMO,
+			accountId:  testMT5Account,
+			positions: []broker.Position{
+				{ID: "1001", Instrument: "EURUSD", Units: 0.5, UnrealizedPL: 12.5},
+				{ID: "1002", Instrument: "GBPUSD", Units: -1, UnrealizedPL: -3},
+			},
+			orders: []broker.Order{{ID: "2001", Instrument: "EURUSD", Type: "BUY_LIMIT", Units: 0.5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := brokertest.NewServer(testApiKey)
+			defer server.Close()
+
+			server.SetEquity(tt.accountId, 100000)
+			for _, p := range tt.positions {
+				server.AddPosition(tt.accountId, p)
+			}
+			for _, o := range tt.orders {
+				server.AddOrder(tt.accountId, o)
+			}
+
+			adapter := newMockAdapter(t, server, tt.brokerType, testApiKey)
+
+			positions, err := adapter.GetOpenPositions(context.Background(), tt.accountId)
+			if err != nil {
+				t.Fatalf("failed to get positions: %v", err)
+			}
+			if len(positions) != len(tt.positions) {
+				t.Fatalf("expected %d positions, got %+v", len(tt.positions), positions)
+			}
+			for i := range positions {
+				if positions[i] != tt.positions[i] {
+					t.Errorf("position %d = %+v, want %+v", i, positions[i], tt.positions[i])
+				}
+			}
+
+			if err := broker.Flatten(context.Background(), adapter, tt.accountId); err != nil {
+				t.Fatalf("failed to flatten: %v", err)
+			}
+
+			if p := server.Positions(tt.accountId); len(p) != 0 {
+				t.Errorf("expected all positions closed, got %+v", p)
+			}
+			if o := server.Orders(tt.accountId); len(o) != 0 {
+				t.Errorf("expected all orders cancelled, got %+v", o)
+			}
+		})
+	}
+}
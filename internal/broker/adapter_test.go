@@ -30,19 +30,18 @@ func setupTestEnv(t *testing.T) config.BrokersConfig {
 		}
 	}
 
-	o := config.OandaConfig{
+	o := config.SingleConnectionConfig{
 		ApiKey:  os.Getenv("OANDA_API_KEY"),
 		BaseUrl: os.Getenv("OANDA_API_URL"),
 	}
 
-	m := config.MT5Config{
+	m := config.SingleConnectionConfig{
 		ApiKey:  os.Getenv("MT5_API_KEY"),
 		BaseUrl: os.Getenv("MT5_API_URL"),
 	}
 
 	return config.BrokersConfig{
-		Oanda: o,
-		MT5:   m,
+		Single: map[string]config.SingleConnectionConfig{config.BrokerOanda: o, config.BrokerMT5: m},
 	}
 }
 
@@ -54,7 +53,7 @@ func TestGetAccountFromOandaIntegration(t *testing.T) {
 		Timeout: 10 * time.Second,
 	}
 
-	client, err := NewAdapter(httpClient, "OANDA", config.BrokersConfig{Oanda: cfg.Oanda})
+	client, err := NewAdapter(httpClient, "OANDA", cfg)
 	if err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
@@ -83,7 +82,7 @@ func TestGetAccountFromMT5Integration(t *testing.T) {
 		Timeout: 10 * time.Second,
 	}
 
-	client, err := NewAdapter(httpClient, "MT5_FTMO", config.BrokersConfig{MT5: cfg.MT5})
+	client, err := NewAdapter(httpClient, "MT5_FTMO", cfg)
 	if err != nil {
 		t.Fatalf("failed to create client: %v", err)
 	}
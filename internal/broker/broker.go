@@ -20,4 +20,23 @@ type BrokerWithLastEquity struct {
 	BrokerAccount
 	// LastEquityUpdate is the last time the equity was updated in UTC. MUST BE CONVERTED TO THE REQURIED TIMEZONE WHEN USED
 	LastEquityUpdate *time.Time `db:"last_equity_update"` // May be nil
-}
\ No newline at end of file
+}
+
+// Position is an open position on a broker account
+type Position struct {
+	// ID is the broker identifier used to close the position
+	ID         string
+	Instrument string
+	// Units is the size of the position, negative for short positions
+	Units        float64
+	UnrealizedPL float64
+}
+
+// Order is a pending order on a broker account
+type Order struct {
+	// ID is the broker identifier used to cancel the order
+	ID         string
+	Instrument string
+	Type       string
+	Units      float64
+}
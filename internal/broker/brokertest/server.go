@@ -0,0 +1,363 @@
+// Package brokertest provides a fake broker that speaks the Oanda v3 and MT5 bridge APIs,
+// so adapters and the equity tracker can be tested end to end without real broker accounts
+package brokertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+)
+
+// Fault is an error response returned instead of serving a request
+type Fault struct {
+	Status int
+	// RetryAfter is sent as the Retry-After header if set
+	RetryAfter string
+	Body       string
+}
+
+// account is the scripted state of a fake broker account
+type account struct {
+	// equity is the path of equity values returned by successive equity requests. The last value repeats
+	equity    []float64
+	positions []broker.Position
+	orders    []broker.Order
+}
+
+// Broker is a fake broker serving both the Oanda v3 API under /v3 and the MT5 bridge API.
+// Requests must authenticate with the API key in the same way as the real APIs
+type Broker struct {
+	apiKey string
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	accounts map[string]*account
+	latency  time.Duration
+	faults   []Fault
+	requests map[string]int
+}
+
+func NewBroker(apiKey string) *Broker {
+	b := &Broker{
+		apiKey:   apiKey,
+		mux:      http.NewServeMux(),
+		accounts: make(map[string]*account),
+		requests: make(map[string]int),
+	}
+
+	// Oanda v3
+	b.mux.HandleFunc("GET /v3/accounts/{id}", b.oanda(b.oandaAccount))
+	b.mux.HandleFunc("GET /v3/accounts/{id}/openPositions", b.oanda(b.oandaOpenPositions))
+	b.mux.HandleFunc("PUT /v3/accounts/{id}/positions/{instrument}/close", b.oanda(b.oandaClosePosition))
+	b.mux.HandleFunc("GET /v3/accounts/{id}/pendingOrders", b.oanda(b.oandaPendingOrders))
+	b.mux.HandleFunc("PUT /v3/accounts/{id}/orders/{orderId}/cancel", b.oanda(b.cancelOrder("orderId")))
+
+	// MT5 bridge
+	b.mux.HandleFunc("GET /accounts/{id}", b.mt5(b.mt5Account))
+	b.mux.HandleFunc("GET /accounts/{id}/positions", b.mt5(b.mt5Positions))
+	b.mux.HandleFunc("POST /accounts/{id}/positions/{ticket}/close", b.mt5(b.mt5ClosePosition))
+	b.mux.HandleFunc("GET /accounts/{id}/orders", b.mt5(b.mt5Orders))
+	b.mux.HandleFunc("DELETE /accounts/{id}/orders/{ticket}", b.mt5(b.cancelOrder("ticket")))
+
+	return b
+}
+
+// SetEquity scripts the equity of the account. Each equity request returns the next value of the path,
+// repeating the last value once the path is exhausted. The account is created if it does not exist
+func (b *Broker) SetEquity(accountId string, path ...float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.account(accountId).equity = path
+}
+
+// AddPosition opens a position on the account. Oanda positions are identified by instrument, MT5 positions by a numeric ticket
+func (b *Broker) AddPosition(accountId string, p broker.Position) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a := b.account(accountId)
+	a.positions = append(a.positions, p)
+}
+
+// AddOrder places a pending order on the account. MT5 orders are identified by a numeric ticket
+func (b *Broker) AddOrder(accountId string, o broker.Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	a := b.account(accountId)
+	a.orders = append(a.orders, o)
+}
+
+// Positions returns the open positions of the account
+func (b *Broker) Positions(accountId string) []broker.Position {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]broker.Position(nil), b.account(accountId).positions...)
+}
+
+// Orders returns the pending orders of the account
+func (b *Broker) Orders(accountId string) []broker.Order {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]broker.Order(nil), b.account(accountId).orders...)
+}
+
+// SetLatency delays every response, unless the request is cancelled first
+func (b *Broker) SetLatency(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.latency = d
+}
+
+// FailNext fails the next n requests with the fault, after any faults already queued
+func (b *Broker) FailNext(n int, f Fault) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := 0; i < n; i++ {
+		b.faults = append(b.faults, f)
+	}
+}
+
+// Requests returns the number of requests served for the route pattern, e.g. "GET /accounts/{id}", including failed requests
+func (b *Broker) Requests(pattern string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.requests[pattern]
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// account returns the account, creating it if needed. Must be called with the lock held
+func (b *Broker) account(accountId string) *account {
+	a, exists := b.accounts[accountId]
+	if !exists {
+		a = &account{}
+		b.accounts[accountId] = a
+	}
+	return a
+}
+
+// accountHandler serves a request for an existing account, with the lock held
+type accountHandler func(w http.ResponseWriter, r *http.Request, a *account)
+
+// oanda wraps a handler with Oanda's bearer token authentication
+func (b *Broker) oanda(h accountHandler) http.HandlerFunc {
+	return b.serve(func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+b.apiKey
+	}, h)
+}
+
+// mt5 wraps a handler with the MT5 bridge's API key authentication
+func (b *Broker) mt5(h accountHandler) http.HandlerFunc {
+	return b.serve(func(r *http.Request) bool {
+		return r.Header.Get("x-api-key") == b.apiKey
+	}, h)
+}
+
+// serve applies latency, fault injection, authentication and account lookup before calling the handler
+func (b *Broker) serve(authorized func(r *http.Request) bool, h accountHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		b.requests[r.Pattern]++
+		latency := b.latency
+		var fault *Fault
+		if len(b.faults) > 0 {
+			fault = &b.faults[0]
+			b.faults = b.faults[1:]
+		}
+		b.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if fault != nil {
+			if fault.RetryAfter != "" {
+				w.Header().Set("Retry-After", fault.RetryAfter)
+			}
+			http.Error(w, fault.Body, fault.Status)
+			return
+		}
+
+		if !authorized(r) {
+			http.Error(w, `{"errorMessage":"Insufficient authorization to perform request."}`, http.StatusUnauthorized)
+			return
+		}
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		a, exists := b.accounts[r.PathValue("id")]
+		if !exists {
+			http.Error(w, `{"errorMessage":"Invalid value specified for 'accountID'"}`, http.StatusNotFound)
+			return
+		}
+
+		h(w, r, a)
+	}
+}
+
+// nextEquity returns the next equity of the scripted path. Must be called with the lock held
+func (a *account) nextEquity() float64 {
+	if len(a.equity) == 0 {
+		return 0
+	}
+	e := a.equity[0]
+	if len(a.equity) > 1 {
+		a.equity = a.equity[1:]
+	}
+	return e
+}
+
+func (b *Broker) oandaAccount(w http.ResponseWriter, _ *http.Request, a *account) {
+	writeJSON(w, broker.OandaAccountResponse{
+		Account: broker.OandaAccount{Equity: formatDecimal(a.nextEquity())},
+	})
+}
+
+func (b *Broker) oandaOpenPositions(w http.ResponseWriter, _ *http.Request, a *account) {
+	res := broker.OandaPositionsResponse{Positions: []broker.OandaPosition{}}
+	for _, p := range a.positions {
+		op := broker.OandaPosition{
+			Instrument:   p.Instrument,
+			Long:         broker.OandaPositionSide{Units: "0"},
+			Short:        broker.OandaPositionSide{Units: "0"},
+			UnrealizedPL: formatDecimal(p.UnrealizedPL),
+		}
+		if p.Units > 0 {
+			op.Long.Units = formatDecimal(p.Units)
+		} else {
+			op.Short.Units = formatDecimal(p.Units)
+		}
+		res.Positions = append(res.Positions, op)
+	}
+	writeJSON(w, res)
+}
+
+func (b *Broker) oandaClosePosition(w http.ResponseWriter, r *http.Request, a *account) {
+	var req broker.OandaPositionCloseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.LongUnits == "" && req.ShortUnits == "") {
+		http.Error(w, `{"errorMessage":"Invalid close request"}`, http.StatusBadRequest)
+		return
+	}
+
+	instrument := r.PathValue("instrument")
+	for i, p := range a.positions {
+		if p.Instrument == instrument {
+			a.positions = append(a.positions[:i], a.positions[i+1:]...)
+			writeJSON(w, map[string]string{"instrument": instrument})
+			return
+		}
+	}
+	http.Error(w, `{"errorMessage":"The Position requested does not exist"}`, http.StatusNotFound)
+}
+
+func (b *Broker) oandaPendingOrders(w http.ResponseWriter, _ *http.Request, a *account) {
+	res := broker.OandaOrdersResponse{Orders: []broker.OandaOrder{}}
+	for _, o := range a.orders {
+		res.Orders = append(res.Orders, broker.OandaOrder{
+			ID:         o.ID,
+			Type:       o.Type,
+			Instrument: o.Instrument,
+			Units:      formatDecimal(o.Units),
+		})
+	}
+	writeJSON(w, res)
+}
+
+func (b *Broker) mt5Account(w http.ResponseWriter, _ *http.Request, a *account) {
+	writeJSON(w, broker.MT5AccountResponse{Equity: a.nextEquity()})
+}
+
+func (b *Broker) mt5Positions(w http.ResponseWriter, _ *http.Request, a *account) {
+	res := []broker.MT5Position{}
+	for _, p := range a.positions {
+		ticket, _ := strconv.ParseInt(p.ID, 10, 64)
+		side := "BUY"
+		if p.Units < 0 {
+			side = "SELL"
+		}
+		res = append(res, broker.MT5Position{
+			Ticket: ticket,
+			Symbol: p.Instrument,
+			Type:   side,
+			Volume: math.Abs(p.Units),
+			Profit: p.UnrealizedPL,
+		})
+	}
+	writeJSON(w, res)
+}
+
+func (b *Broker) mt5ClosePosition(w http.ResponseWriter, r *http.Request, a *account) {
+	ticket := r.PathValue("ticket")
+	for i, p := range a.positions {
+		if p.ID == ticket {
+			a.positions = append(a.positions[:i], a.positions[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.Error(w, `{"error":"position not found"}`, http.StatusNotFound)
+}
+
+func (b *Broker) mt5Orders(w http.ResponseWriter, _ *http.Request, a *account) {
+	res := []broker.MT5Order{}
+	for _, o := range a.orders {
+		ticket, _ := strconv.ParseInt(o.ID, 10, 64)
+		res = append(res, broker.MT5Order{
+			Ticket: ticket,
+			Symbol: o.Instrument,
+			Type:   o.Type,
+			Volume: o.Units,
+		})
+	}
+	writeJSON(w, res)
+}
+
+// cancelOrder returns a handler cancelling the order identified by the path value
+func (b *Broker) cancelOrder(idValue string) accountHandler {
+	return func(w http.ResponseWriter, r *http.Request, a *account) {
+		id := r.PathValue(idValue)
+		for i, o := range a.orders {
+			if o.ID == id {
+				a.orders = append(a.orders[:i], a.orders[i+1:]...)
+				writeJSON(w, map[string]string{"orderID": id})
+				return
+			}
+		}
+		http.Error(w, fmt.Sprintf(`{"errorMessage":"The order %s does not exist"}`, id), http.StatusNotFound)
+	}
+}
+
+// Server is a fake broker listening on a local port
+type Server struct {
+	*Broker
+	*httptest.Server
+}
+
+// NewServer starts a fake broker with the API key. The caller must call Close when done
+func NewServer(apiKey string) *Server {
+	b := NewBroker(apiKey)
+	return &Server{Broker: b, Server: httptest.NewServer(b)}
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// formatDecimal formats a value as a decimal string, as Oanda returns numbers
+func formatDecimal(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
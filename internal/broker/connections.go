@@ -0,0 +1,139 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+)
+
+// Connection is a named connection to a broker API, such as one of several Oanda logins or MT5 bridges
+type Connection struct {
+	Name string
+	// Type is the broker type of the accounts served by the connection
+	Type string
+	// Env is the broker environment of the accounts served, e.g. DEMO or LIVE. Empty serves accounts of any environment
+	Env string
+	// Accounts are the IDs of the accounts served. If set, the connection only serves these accounts
+	Accounts []string
+	Adapter  BrokerAdapter
+}
+
+// Connections maps broker accounts to the connection serving them
+type Connections struct {
+	connections []Connection
+	// byAccount maps an account ID to the index of the connection it is explicitly assigned to
+	byAccount map[string]int
+}
+
+// NewConnections returns the connections, checking names are unique and accounts are assigned to one connection at most
+func NewConnections(connections ...Connection) (*Connections, error) {
+	c := &Connections{connections: connections, byAccount: make(map[string]int)}
+
+	names := make(map[string]bool)
+	for i, conn := range connections {
+		if names[conn.Name] {
+			return nil, fmt.Errorf("duplicate broker connection: %s", conn.Name)
+		}
+		names[conn.Name] = true
+
+		for _, accountId := range conn.Accounts {
+			if other, exists := c.byAccount[accountId]; exists {
+				return nil, fmt.Errorf("account %s is assigned to broker connections %s and %s", accountId, connections[other].Name, conn.Name)
+			}
+			c.byAccount[accountId] = i
+		}
+	}
+
+	return c, nil
+}
+
+// LoadConnections creates the adapters of the configured broker connections. Each enabled broker with a single
+// connection configuration is a connection named after its broker type, serving all of its accounts
+func LoadConnections(client *http.Client, cfg config.BrokersConfig) (*Connections, error) {
+	// Single connections of unregistered brokers are rejected even if they are not enabled, so typos are not ignored
+	for name := range cfg.Single {
+		if _, exists := LookupName(name); !exists {
+			return nil, fmt.Errorf("unsupported broker: %s", name)
+		}
+	}
+
+	var configs []config.ConnectionConfig
+	for _, name := range cfg.Enabled {
+		r, exists := LookupName(name)
+		if !exists {
+			return nil, fmt.Errorf("unsupported broker: %s", name)
+		}
+		configs = append(configs, singleConnection(r, cfg))
+	}
+	configs = append(configs, cfg.Connections...)
+
+	var connections []Connection
+	for _, conn := range configs {
+		adapter, err := NewConnectionAdapter(client, conn)
+		if err != nil {
+			return nil, fmt.Errorf("error creating broker connection %s: %v", conn.Name, err)
+		}
+		connections = append(connections, Connection{
+			Name:     conn.Name,
+			Type:     conn.Type,
+			Env:      conn.Env,
+			Accounts: conn.Accounts,
+			Adapter:  adapter,
+		})
+	}
+
+	return NewConnections(connections...)
+}
+
+// singleConnection returns the single connection configuration of the registered broker, named after its broker type
+func singleConnection(r Registration, cfg config.BrokersConfig) config.ConnectionConfig {
+	conn := cfg.SingleConnection(r.Name)
+	conn.Name = r.Type
+	conn.Type = r.Type
+	return conn
+}
+
+// Resolve returns the connection serving the account. An account assigned to a connection by ID uses it. Otherwise the
+// connection of the account's broker type and environment is used, falling back to one serving any environment
+func (c *Connections) Resolve(account BrokerAccount) (Connection, error) {
+	if i, exists := c.byAccount[account.AccountID]; exists {
+		conn := c.connections[i]
+		if conn.Type != account.BrokerType {
+			return Connection{}, fmt.Errorf("account %s of broker type %s is assigned to broker connection %s of type %s", account.AccountID, account.BrokerType, conn.Name, conn.Type)
+		}
+		return conn, nil
+	}
+
+	var envMatches, anyEnv []Connection
+	for _, conn := range c.connections {
+		if conn.Type != account.BrokerType || len(conn.Accounts) > 0 {
+			continue
+		}
+		switch {
+		case conn.Env == "":
+			anyEnv = append(anyEnv, conn)
+		case strings.EqualFold(conn.Env, account.BrokerEnv):
+			envMatches = append(envMatches, conn)
+		}
+	}
+
+	for _, matches := range [][]Connection{envMatches, anyEnv} {
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], nil
+		default:
+			return Connection{}, fmt.Errorf("multiple broker connections serve broker type %s in env %s, assign account %s to one of them", account.BrokerType, account.BrokerEnv, account.AccountID)
+		}
+	}
+
+	return Connection{}, fmt.Errorf("no broker connection serves broker type %s in env %s", account.BrokerType, account.BrokerEnv)
+}
+
+// All returns every connection, in the order configured
+func (c *Connections) All() []Connection {
+	return c.connections
+}
@@ -0,0 +1,107 @@
+package broker
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+)
+
+// namedAdapter is a no-op adapter identified by name
+type namedAdapter struct {
+	BrokerAdapter
+	name string
+}
+
+func TestConnectionsResolve(t *testing.T) {
+	conn := func(name, brokerType, env string, accounts ...string) Connection {
+		return Connection{Name: name, Type: brokerType, Env: env, Accounts: accounts, Adapter: namedAdapter{name: name}}
+	}
+	connections, err := NewConnections(
+		conn("oanda-demo", Oanda, "DEMO"),
+		conn("oanda-live", Oanda, "LIVE"),
+		conn("oanda-second-login", Oanda, "LIVE", "001-002"),
+		conn("ftmo-bridge", MT5FTMO, ""),
+		conn("fundednext-bridge", MT5FTMO, "", "555"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		account BrokerAccount
+		want    string
+		wantErr bool
+	}{
+		{name: "Matches env", account: BrokerAccount{AccountID: "001-001", BrokerType: Oanda, BrokerEnv: "LIVE"}, want: "oanda-live"},
+		{name: "Matches env ignoring case", account: BrokerAccount{AccountID: "001-001", BrokerType: Oanda, BrokerEnv: "demo"}, want: "oanda-demo"},
+		{name: "Assigned by account", account: BrokerAccount{AccountID: "001-002", BrokerType: Oanda, BrokerEnv: "LIVE"}, want: "oanda-second-login"},
+		{name: "Any env", account: BrokerAccount{AccountID: "123", BrokerType: MT5FTMO, BrokerEnv: "LIVE"}, want: "ftmo-bridge"},
+		{name: "Assigned bridge", account: BrokerAccount{AccountID: "555", BrokerType: MT5FTMO, BrokerEnv: "LIVE"}, want: "fundednext-bridge"},
+		{name: "No connection for env", account: BrokerAccount{AccountID: "001-003", BrokerType: Oanda, BrokerEnv: "STAGING"}, wantErr: true},
+		{name: "No connection for type", account: BrokerAccount{AccountID: "1", BrokerType: "IG"}, wantErr: true},
+		{name: "Assigned to another type", account: BrokerAccount{AccountID: "555", BrokerType: Oanda, BrokerEnv: "LIVE"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := connections.Resolve(tt.account)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Name != tt.want || got.Adapter.(namedAdapter).name != tt.want) {
+				t.Errorf("Resolve() = %s, want %s", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectionsAmbiguous(t *testing.T) {
+	connections, err := NewConnections(
+		Connection{Name: "first", Type: Oanda},
+		Connection{Name: "second", Type: Oanda},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := connections.Resolve(BrokerAccount{AccountID: "1", BrokerType: Oanda}); err == nil {
+		t.Errorf("expected an error for an account served by several connections")
+	}
+}
+
+func TestNewConnectionsErrors(t *testing.T) {
+	if _, err := NewConnections(Connection{Name: "a", Type: Oanda}, Connection{Name: "a", Type: MT5FTMO}); err == nil {
+		t.Errorf("expected an error for duplicate names")
+	}
+	if _, err := NewConnections(Connection{Name: "a", Type: Oanda, Accounts: []string{"1"}}, Connection{Name: "b", Type: Oanda, Accounts: []string{"1"}}); err == nil {
+		t.Errorf("expected an error for an account assigned twice")
+	}
+}
+
+func TestLoadConnections(t *testing.T) {
+	connections, err := LoadConnections(http.DefaultClient, config.BrokersConfig{
+		Enabled: []string{config.BrokerOanda},
+		Single:  map[string]config.SingleConnectionConfig{config.BrokerOanda: {ApiKey: "key", BaseUrl: "http://oanda"}},
+		Connections: []config.ConnectionConfig{
+			{Name: "ftmo", Type: MT5FTMO, ApiKey: "key", BaseUrl: "http://ftmo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	all := connections.All()
+	if len(all) != 2 || all[0].Name != Oanda || all[1].Name != "ftmo" {
+		t.Fatalf("unexpected connections %+v", all)
+	}
+	if _, ok := all[1].Adapter.(*MT5Adapter); !ok {
+		t.Errorf("expected an MT5 adapter, got %T", all[1].Adapter)
+	}
+
+	if _, err := LoadConnections(http.DefaultClient, config.BrokersConfig{
+		Connections: []config.ConnectionConfig{{Name: "ig", Type: "IG", ApiKey: "key", BaseUrl: "http://ig"}},
+	}); err == nil {
+		t.Errorf("expected an error for an unsupported broker type")
+	}
+}
@@ -0,0 +1,185 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordedRequest is a request received by a recordingServer
+type recordedRequest struct {
+	route string
+	body  string
+}
+
+// recordingServer responds to GET requests with canned JSON by path, and records every other request
+type recordingServer struct {
+	*httptest.Server
+	responses map[string]string
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+func newRecordingServer(t *testing.T, responses map[string]string) *recordingServer {
+	s := &recordingServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			body, ok := s.responses[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(body))
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, recordedRequest{route: r.Method + " " + r.URL.Path, body: strings.TrimSpace(string(body))})
+		s.mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *recordingServer) recorded() []recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func TestOandaCloseAllPositions(t *testing.T) {
+	server := newRecordingServer(t, map[string]string{
+		"/v3/accounts/001/openPositions": `{"positions": [
+			{"instrument": "EUR_USD", "long": {"units": "1000"}, "short": {"units": "0"}},
+			{"instrument": "GBP_USD", "long": {"units": "0"}, "short": {"units": "-2000"}},
+			{"instrument": "USD_JPY", "long": {"units": "100"}, "short": {"units": "-300"}},
+			{"instrument": "AUD_USD", "long": {"units": "0"}, "short": {}}
+		]}`,
+	})
+	adapter := newOandaAdapter(Oanda, server.Client(), "key", server.URL)
+
+	if err := adapter.CloseAllPositions(context.Background(), "001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Only the sides with units are closed, and positions without any are skipped
+	expected := []recordedRequest{
+		{route: "PUT /v3/accounts/001/positions/EUR_USD/close", body: `{"longUnits":"ALL"}`},
+		{route: "PUT /v3/accounts/001/positions/GBP_USD/close", body: `{"shortUnits":"ALL"}`},
+		{route: "PUT /v3/accounts/001/positions/USD_JPY/close", body: `{"longUnits":"ALL","shortUnits":"ALL"}`},
+	}
+	if got := server.recorded(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("requests = %+v, want %+v", got, expected)
+	}
+}
+
+func TestOandaCancelAllOrders(t *testing.T) {
+	server := newRecordingServer(t, map[string]string{
+		"/v3/accounts/001/pendingOrders": `{"orders": [
+			{"id": "42", "type": "LIMIT", "instrument": "EUR_USD", "units": "1000"},
+			{"id": "43", "type": "STOP_LOSS"}
+		]}`,
+	})
+	adapter := newOandaAdapter(Oanda, server.Client(), "key", server.URL)
+
+	if err := adapter.CancelAllOrders(context.Background(), "001"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []recordedRequest{
+		{route: "PUT /v3/accounts/001/orders/42/cancel"},
+		{route: "PUT /v3/accounts/001/orders/43/cancel"},
+	}
+	if got := server.recorded(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("requests = %+v, want %+v", got, expected)
+	}
+}
+
+func TestMT5Flatten(t *testing.T) {
+	server := newRecordingServer(t, map[string]string{
+		"/accounts/555/positions": `[
+			{"ticket": 1001, "symbol": "EURUSD", "type": "BUY", "volume": 0.5},
+			{"ticket": 1002, "symbol": "GBPUSD", "type": "SELL", "volume": 1}
+		]`,
+		"/accounts/555/orders": `[{"ticket": 2001, "symbol": "EURUSD", "type": "BUY_LIMIT", "volume": 0.5}]`,
+	})
+	adapter := newMT5Adapter(MT5FTMO, server.Client(), "key", server.URL)
+
+	if err := Flatten(context.Background(), adapter, "555"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Orders are deleted before positions are closed
+	expected := []recordedRequest{
+		{route: "DELETE /accounts/555/orders/2001"},
+		{route: "POST /accounts/555/positions/1001/close"},
+		{route: "POST /accounts/555/positions/1002/close"},
+	}
+	if got := server.recorded(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("requests = %+v, want %+v", got, expected)
+	}
+}
+
+// flattenAdapter records the order of flatten calls, failing them with the configured errors
+type flattenAdapter struct {
+	BrokerAdapter
+	cancelErr error
+	closeErr  error
+	calls     []string
+}
+
+func (f *flattenAdapter) CancelAllOrders(_ context.Context, _ string) error {
+	f.calls = append(f.calls, "cancel")
+	return f.cancelErr
+}
+
+func (f *flattenAdapter) CloseAllPositions(_ context.Context, _ string) error {
+	f.calls = append(f.calls, "close")
+	return f.closeErr
+}
+
+func TestFlatten(t *testing.T) {
+	cancelErr := errors.New("order rejected")
+	closeErr := errors.New("market closed")
+
+	tests := []struct {
+		name      string
+		cancelErr error
+		closeErr  error
+		wantErrs  []error
+	}{
+		{name: "Succeeds"},
+		{name: "Closes positions after cancel fails", cancelErr: cancelErr, wantErrs: []error{cancelErr}},
+		{name: "Close fails", closeErr: closeErr, wantErrs: []error{closeErr}},
+		{name: "Joins both errors", cancelErr: cancelErr, closeErr: closeErr, wantErrs: []error{cancelErr, closeErr}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := &flattenAdapter{cancelErr: tt.cancelErr, closeErr: tt.closeErr}
+
+			err := Flatten(context.Background(), adapter, "1")
+
+			if !reflect.DeepEqual(adapter.calls, []string{"cancel", "close"}) {
+				t.Errorf("calls = %v, want orders cancelled before positions are closed", adapter.calls)
+			}
+			if (err != nil) != (len(tt.wantErrs) > 0) {
+				t.Fatalf("Flatten() error = %v, want %v", err, tt.wantErrs)
+			}
+			for _, want := range tt.wantErrs {
+				if !errors.Is(err, want) {
+					t.Errorf("Flatten() error = %v, want it to wrap %v", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package broker
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "risk_manager_broker_request_duration_seconds",
+		Help:    "Duration of each attempt of a request to a broker.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"broker", "method"})
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "risk_manager_broker_request_errors_total",
+		Help: "Failed attempts of requests to a broker, and requests rejected by its open circuit breaker.",
+	}, []string{"broker", "reason"})
+)
+
+// Reasons a broker request failed
+const (
+	reasonTransport   = "transport"
+	reasonRateLimited = "rate_limited"
+	reasonServer      = "server_error"
+	reasonClient      = "client_error"
+	reasonCircuitOpen = "circuit_open"
+)
+
+// errorReason classifies a failed request attempt for metrics
+func errorReason(err error) string {
+	var se *statusError
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return reasonCircuitOpen
+	case !errors.As(err, &se):
+		return reasonTransport
+	case se.statusCode == http.StatusTooManyRequests:
+		return reasonRateLimited
+	case se.statusCode >= 500:
+		return reasonServer
+	default:
+		return reasonClient
+	}
+}
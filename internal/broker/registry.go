@@ -0,0 +1,153 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+)
+
+// Factory creates an adapter for a connection to the broker
+type Factory func(client *http.Client, conn config.ConnectionConfig) (BrokerAdapter, error)
+
+// Field is a setting of a broker connection. api_key and base_url are the connection's API key and URL,
+// any other field is read from the connection's options
+type Field struct {
+	Name     string
+	Required bool
+}
+
+// Settings of a broker connection that are not options
+const (
+	FieldApiKey  = "api_key"
+	FieldBaseUrl = "base_url"
+)
+
+// Registration describes a broker type that connections can be made to
+type Registration struct {
+	// Type is the broker type, matching the broker_type of the broker's accounts
+	Type string
+	// Name is the broker's name in BROKERS, whose single connection is configured under brokers.<name>.
+	// Empty if the broker can only be connected to by named connections
+	Name string
+	// Schema are the settings of a connection to the broker
+	Schema []Field
+	// DefaultProfile is the prop firm profile of the broker's accounts without an assigned profile
+	DefaultProfile string
+	New            Factory
+}
+
+// registry holds the registered broker types. Adapters register themselves on init
+var registry = struct {
+	mu    sync.RWMutex
+	types map[string]Registration
+}{types: make(map[string]Registration)}
+
+// Register makes a broker type available to connections, and is called from the init function of the adapter's package.
+// It panics if the registration is incomplete or the type is already registered
+func Register(r Registration) {
+	if r.Type == "" || r.New == nil {
+		panic("broker: registration requires a type and factory")
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, exists := registry.types[r.Type]; exists {
+		panic(fmt.Sprintf("broker: type %s registered twice", r.Type))
+	}
+	if _, exists := lookupName(r.Name); exists {
+		panic(fmt.Sprintf("broker: name %s registered twice", r.Name))
+	}
+	registry.types[r.Type] = r
+}
+
+// Lookup returns the registration of the broker type, false if it is not registered
+func Lookup(brokerType string) (Registration, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	r, exists := registry.types[brokerType]
+	return r, exists
+}
+
+// LookupName returns the registration of the broker with the name in BROKERS, false if there is none
+func LookupName(name string) (Registration, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return lookupName(name)
+}
+
+func lookupName(name string) (Registration, bool) {
+	if name == "" {
+		return Registration{}, false
+	}
+	for _, r := range registry.types {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Registration{}, false
+}
+
+// Registered returns the registered broker types, sorted by type
+func Registered() []Registration {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	registrations := make([]Registration, 0, len(registry.types))
+	for _, r := range registry.types {
+		registrations = append(registrations, r)
+	}
+	slices.SortFunc(registrations, func(a, b Registration) int { return strings.Compare(a.Type, b.Type) })
+	return registrations
+}
+
+// DefaultProfiles maps each registered broker type to the profile of its accounts without an assigned profile
+func DefaultProfiles() map[string]string {
+	defaults := make(map[string]string)
+	for _, r := range Registered() {
+		if r.DefaultProfile != "" {
+			defaults[r.Type] = r.DefaultProfile
+		}
+	}
+	return defaults
+}
+
+// settings returns the names of the settings in the schema
+func (r Registration) settings() []string {
+	settings := make([]string, 0, len(r.Schema))
+	for _, field := range r.Schema {
+		settings = append(settings, field.Name)
+	}
+	return settings
+}
+
+// validate checks the connection has the required settings of the schema, and no options outside of it
+func (r Registration) validate(conn config.ConnectionConfig) error {
+	known := make(map[string]bool)
+	for _, field := range r.Schema {
+		known[field.Name] = true
+
+		var value string
+		switch field.Name {
+		case FieldApiKey:
+			value = conn.ApiKey
+		case FieldBaseUrl:
+			value = conn.BaseUrl
+		default:
+			value = conn.Options[field.Name]
+		}
+		if field.Required && value == "" {
+			return fmt.Errorf("%s (%s) is required for broker type %s", field.Name, conn.EnvVar(field.Name), r.Type)
+		}
+	}
+
+	for option := range conn.Options {
+		if !known[option] {
+			return fmt.Errorf("unknown option %s for broker type %s", option, r.Type)
+		}
+	}
+	return nil
+}
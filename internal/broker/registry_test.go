@@ -0,0 +1,211 @@
+package broker
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+)
+
+// testBrokerType is registered once for the package's tests, as registrations can't be removed
+const testBrokerType = "TEST_BROKER"
+
+func init() {
+	Register(Registration{
+		Type:           testBrokerType,
+		Name:           "test",
+		Schema:         []Field{{Name: FieldApiKey, Required: true}, {Name: "account_type", Required: true}, {Name: "region"}},
+		DefaultProfile: profiles.Default,
+		New: func(client *http.Client, conn config.ConnectionConfig) (BrokerAdapter, error) {
+			return namedAdapter{name: conn.Name + "/" + conn.Options["account_type"]}, nil
+		},
+	})
+}
+
+func TestLoadConnectionsUsesRegistry(t *testing.T) {
+	connections, err := LoadConnections(http.DefaultClient, config.BrokersConfig{
+		Connections: []config.ConnectionConfig{
+			{Name: "test-live", Type: testBrokerType, ApiKey: "key", Options: map[string]string{"account_type": "spread_bet"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	conn, err := connections.Resolve(BrokerAccount{AccountID: "1", BrokerType: testBrokerType})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := conn.Adapter.(namedAdapter).name; got != "test-live/spread_bet" {
+		t.Errorf("adapter = %s, want test-live/spread_bet", got)
+	}
+}
+
+func TestLoadConnectionsSingleConnections(t *testing.T) {
+	connections, err := LoadConnections(http.DefaultClient, config.BrokersConfig{
+		Enabled: []string{"test"},
+		Single: map[string]config.SingleConnectionConfig{
+			"test": {ApiKey: "key", Options: map[string]string{"account_type": "cfd"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if all := connections.All(); len(all) != 1 || all[0].Name != testBrokerType || all[0].Adapter.(namedAdapter).name != testBrokerType+"/cfd" {
+		t.Errorf("unexpected connections %+v", all)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.BrokersConfig
+		wantErr string
+	}{
+		{
+			name: "Enabled broker not configured",
+			cfg: config.BrokersConfig{
+				Enabled: []string{config.BrokerOanda, config.BrokerMT5},
+				Single:  map[string]config.SingleConnectionConfig{config.BrokerOanda: {ApiKey: "key", BaseUrl: "http://oanda"}},
+			},
+			wantErr: "MT5_API_KEY",
+		},
+		{
+			name: "Missing required option",
+			cfg: config.BrokersConfig{
+				Enabled: []string{"test"},
+				Single:  map[string]config.SingleConnectionConfig{"test": {ApiKey: "key", Options: map[string]string{"region": "uk"}}},
+			},
+			wantErr: "account_type (TEST_ACCOUNT_TYPE)",
+		},
+		{
+			name:    "Unsupported broker",
+			cfg:     config.BrokersConfig{Enabled: []string{"ig"}},
+			wantErr: "unsupported broker: ig",
+		},
+		{
+			name:    "Unsupported broker configured",
+			cfg:     config.BrokersConfig{Single: map[string]config.SingleConnectionConfig{"oandaa": {ApiKey: "key"}}},
+			wantErr: "unsupported broker: oandaa",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConnections(http.DefaultClient, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConnections() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewConnectionAdapterValidatesSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		conn    config.ConnectionConfig
+		wantErr string
+	}{
+		{
+			name: "Valid",
+			conn: config.ConnectionConfig{Name: "test", Type: testBrokerType, ApiKey: "key", Options: map[string]string{"account_type": "cfd", "region": "uk"}},
+		},
+		{
+			name:    "Missing required setting",
+			conn:    config.ConnectionConfig{Name: "test", Type: testBrokerType, Options: map[string]string{"account_type": "cfd"}},
+			wantErr: "BROKER_TEST_API_KEY",
+		},
+		{
+			name:    "Missing required option",
+			conn:    config.ConnectionConfig{Name: "test", Type: testBrokerType, ApiKey: "key"},
+			wantErr: "BROKER_TEST_ACCOUNT_TYPE",
+		},
+		{
+			name:    "Unknown option",
+			conn:    config.ConnectionConfig{Name: "test", Type: testBrokerType, ApiKey: "key", Options: map[string]string{"account_type": "cfd", "acount": "1"}},
+			wantErr: "unknown option acount",
+		},
+		{
+			name:    "Built in broker not configured",
+			conn:    config.ConnectionConfig{Name: "ftmo-live", Type: MT5FTMO, BaseUrl: "https://bridge.example.com"},
+			wantErr: "BROKER_FTMO_LIVE_API_KEY",
+		},
+		{
+			name:    "Unregistered type",
+			conn:    config.ConnectionConfig{Name: "ig", Type: "IG", ApiKey: "key"},
+			wantErr: "unsupported broker type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewConnectionAdapter(http.DefaultClient, tt.conn)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewConnectionAdapter() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewConnectionAdapter() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewConnectionAdapterReadsSchemaFromEnv(t *testing.T) {
+	t.Setenv("TEST_ACCOUNT_TYPE", "cfd")
+	t.Setenv("BROKER_TEST_LIVE_ACCOUNT_TYPE", "spread_bet")
+
+	connections, err := LoadConnections(http.DefaultClient, config.BrokersConfig{
+		Enabled: []string{"test"},
+		Single:  map[string]config.SingleConnectionConfig{"test": {ApiKey: "key"}},
+		Connections: []config.ConnectionConfig{
+			{Name: "test-live", Type: testBrokerType, Env: "live", ApiKey: "key"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{testBrokerType: testBrokerType + "/cfd", "test-live": "test-live/spread_bet"}
+	for _, conn := range connections.All() {
+		if got := conn.Adapter.(namedAdapter).name; got != want[conn.Name] {
+			t.Errorf("adapter of %s = %s, want %s", conn.Name, got, want[conn.Name])
+		}
+	}
+}
+
+func TestRegisterRejectsInvalidRegistrations(t *testing.T) {
+	factory := func(*http.Client, config.ConnectionConfig) (BrokerAdapter, error) { return nil, nil }
+
+	tests := []struct {
+		name string
+		r    Registration
+	}{
+		{name: "Missing type", r: Registration{New: factory}},
+		{name: "Missing factory", r: Registration{Type: "UNREGISTERED"}},
+		{name: "Duplicate type", r: Registration{Type: Oanda, New: factory}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected Register to panic")
+				}
+			}()
+			Register(tt.r)
+		})
+	}
+}
+
+func TestDefaultProfiles(t *testing.T) {
+	defaults := DefaultProfiles()
+	want := map[string]string{Oanda: profiles.Default, MT5FTMO: profiles.FTMO, testBrokerType: profiles.Default}
+	for brokerType, profile := range want {
+		if defaults[brokerType] != profile {
+			t.Errorf("default profile of %s = %s, want %s", brokerType, defaults[brokerType], profile)
+		}
+	}
+}
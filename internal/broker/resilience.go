@@ -0,0 +1,288 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/utils"
+)
+
+// ErrCircuitOpen is returned without calling the broker while its circuit breaker is open
+var ErrCircuitOpen = errors.New("broker unreachable: circuit breaker open")
+
+// RetryPolicy configures how failed broker requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is used by all adapters
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+}
+
+// Default circuit breaker settings used by all adapters
+const (
+	DefaultFailureThreshold = 5
+	DefaultOpenDuration     = 30 * time.Second
+)
+
+// backoff returns the jittered delay before the given retry, in [d/2, d] where d doubles each retry up to MaxBackoff
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff << retry
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// CircuitState is the state of a broker's circuit breaker
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests until the open duration has elapsed
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through to check if the broker has recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitReporter is implemented by adapters that expose the state of their circuit breaker
+type CircuitReporter interface {
+	CircuitState() CircuitState
+}
+
+// CircuitBreaker stops calling a broker after consecutive failures, so an unreachable broker
+// fails fast instead of every request waiting for a timeout
+type CircuitBreaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+	timeProvider     utils.TimeProvider
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// probing is true while the half-open probe request is in flight
+	probing bool
+}
+
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		timeProvider:     utils.RealTimeProvider{},
+	}
+}
+
+// State returns the current state of the circuit
+func (c *CircuitBreaker) State() CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+	return c.state
+}
+
+// Allow checks if a request can be made, returning ErrCircuitOpen if not.
+// Every allowed request must be followed by a call to Success or Failure
+func (c *CircuitBreaker) Allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+
+	switch c.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if c.probing {
+			return ErrCircuitOpen
+		}
+		c.probing = true
+	}
+	return nil
+}
+
+// Success records a request that reached the broker, closing the circuit
+func (c *CircuitBreaker) Success() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = CircuitClosed
+	c.failures = 0
+	c.probing = false
+}
+
+// Failure records a request that could not reach the broker, opening the circuit
+// once the failure threshold is hit or if the half-open probe failed
+func (c *CircuitBreaker) Failure() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= c.failureThreshold {
+		c.state = CircuitOpen
+		c.openedAt = c.timeProvider.Now()
+	}
+	c.probing = false
+}
+
+// refresh moves an open circuit to half-open once the open duration has elapsed. Must be called with the lock held
+func (c *CircuitBreaker) refresh() {
+	if c.state == CircuitOpen && c.timeProvider.Now().Sub(c.openedAt) >= c.openDuration {
+		c.state = CircuitHalfOpen
+		c.probing = false
+	}
+}
+
+// resilientClient wraps the http client used by an adapter with retries and a circuit breaker
+type resilientClient struct {
+	// name labels the metrics of the client, e.g. the broker type
+	name    string
+	client  *http.Client
+	retry   RetryPolicy
+	breaker *CircuitBreaker
+}
+
+func newResilientClient(name string, client *http.Client) *resilientClient {
+	return &resilientClient{
+		name:    name,
+		client:  client,
+		retry:   DefaultRetryPolicy,
+		breaker: NewCircuitBreaker(DefaultFailureThreshold, DefaultOpenDuration),
+	}
+}
+
+// statusError is returned for a non 2xx response from the broker
+type statusError struct {
+	statusCode int
+	body       string
+	// retryAfter is the delay requested by the broker in the Retry-After header, 0 if not set
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, body: %s", e.statusCode, e.body)
+}
+
+// retryable checks if the request should be retried after the response status code
+func (e *statusError) retryable() bool {
+	return e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500
+}
+
+// unreachable checks if the error means the broker could not serve the request, and counts against the circuit breaker.
+// Rate limits and client errors mean the broker is up
+func unreachable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.statusCode >= 500
+	}
+	return true
+}
+
+// isIdempotent checks if the request method is safe to retry
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// do runs the request through the circuit breaker, retrying idempotent requests on transport errors,
+// rate limits and server errors. send is called once per attempt and must build a new request each time
+func (rc *resilientClient) do(ctx context.Context, method string, send func() ([]byte, error)) ([]byte, error) {
+	if err := rc.breaker.Allow(); err != nil {
+		requestErrors.WithLabelValues(rc.name, errorReason(err)).Inc()
+		return nil, err
+	}
+
+	attempts := 1
+	if isIdempotent(method) {
+		attempts = max(rc.retry.MaxAttempts, 1)
+	}
+
+	var err error
+retries:
+	for attempt := 0; attempt < attempts; attempt++ {
+		var body []byte
+		start := time.Now()
+		body, err = send()
+		requestDuration.WithLabelValues(rc.name, method).Observe(time.Since(start).Seconds())
+		if err == nil {
+			rc.breaker.Success()
+			return body, nil
+		}
+
+		requestErrors.WithLabelValues(rc.name, errorReason(err)).Inc()
+
+		var se *statusError
+		isStatus := errors.As(err, &se)
+		if isStatus && !se.retryable() {
+			break
+		}
+		if attempt == attempts-1 || ctx.Err() != nil {
+			break
+		}
+
+		wait := rc.retry.backoff(attempt)
+		if isStatus && se.retryAfter > 0 {
+			wait = se.retryAfter
+		}
+		// Don't wait for a retry that can't complete before the deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			break
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			// The breaker is still settled below, or a cancelled half-open probe would hold the circuit open
+			err = fmt.Errorf("%w: %w", err, ctx.Err())
+			break retries
+		}
+	}
+
+	if unreachable(err) {
+		rc.breaker.Failure()
+	} else {
+		rc.breaker.Success()
+	}
+	return nil, err
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date, returning 0 if not set or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,233 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fixedTimeProvider struct {
+	now time.Time
+}
+
+func (f *fixedTimeProvider) Now() time.Time {
+	return f.now
+}
+
+// newTestClient returns a resilient client with short backoffs, so tests don't wait on retries
+func newTestClient(maxAttempts, failureThreshold int) *resilientClient {
+	return &resilientClient{
+		name:    "test",
+		client:  http.DefaultClient,
+		retry:   RetryPolicy{MaxAttempts: maxAttempts, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond},
+		breaker: NewCircuitBreaker(failureThreshold, time.Minute),
+	}
+}
+
+// newStatusServer returns a server responding with the given status codes in order, then 200 for any further requests
+func newStatusServer(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Write([]byte(`{"equity": 100000}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		statuses      []int
+		expectedCalls int32
+		wantErr       bool
+	}{
+		{name: "Retries server errors", method: http.MethodGet, statuses: []int{503, 502}, expectedCalls: 3},
+		{name: "Retries rate limits", method: http.MethodGet, statuses: []int{429}, expectedCalls: 2},
+		{name: "Gives up after max attempts", method: http.MethodGet, statuses: []int{500, 500, 500}, expectedCalls: 3, wantErr: true},
+		{name: "Does not retry client errors", method: http.MethodGet, statuses: []int{404}, expectedCalls: 1, wantErr: true},
+		{name: "Does not retry non idempotent requests", method: http.MethodPost, statuses: []int{503}, expectedCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := newStatusServer(t, tt.statuses...)
+			client := newTestClient(3, 10)
+
+			res, err := makeRequest[MT5AccountResponse](context.Background(), client, tt.method, server.URL, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("makeRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && res.Equity != 100000 {
+				t.Errorf("Equity = %.2f, want 100000", res.Equity)
+			}
+			if calls.Load() != tt.expectedCalls {
+				t.Errorf("calls = %d, want %d", calls.Load(), tt.expectedCalls)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRespectsRetryAfterDeadline(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := makeGET[MT5AccountResponse](ctx, newTestClient(3, 10), server.URL, nil)
+	if err == nil {
+		t.Fatal("expected rate limit error")
+	}
+	// Waiting 30s would overrun the deadline, so no retry is attempted
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestMakeRequestOpensCircuit(t *testing.T) {
+	server, calls := newStatusServer(t, 500, 500, 500)
+	client := newTestClient(1, 2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := makeGET[MT5AccountResponse](context.Background(), client, server.URL, nil); err == nil {
+			t.Fatal("expected server error")
+		}
+	}
+
+	_, err := makeGET[MT5AccountResponse](context.Background(), client, server.URL, nil)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected open circuit not to call the broker, got %d calls", calls.Load())
+	}
+}
+
+func TestDoCancelledProbeSettlesCircuit(t *testing.T) {
+	clock := &fixedTimeProvider{now: time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)}
+	client := newTestClient(3, 1)
+	client.retry.InitialBackoff = time.Minute
+	client.retry.MaxBackoff = time.Minute
+	client.breaker.timeProvider = clock
+
+	client.breaker.Failure()
+	clock.now = clock.now.Add(time.Minute)
+	if client.breaker.State() != CircuitHalfOpen {
+		t.Fatalf("expected circuit half-open, got %s", client.breaker.State())
+	}
+
+	// The probe fails, and the context is cancelled while waiting to retry it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	send := func() ([]byte, error) {
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return nil, &statusError{statusCode: http.StatusServiceUnavailable}
+	}
+
+	if _, err := client.do(ctx, http.MethodGet, send); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled request, got %v", err)
+	}
+
+	if client.breaker.State() != CircuitOpen {
+		t.Fatalf("expected failed probe to re-open circuit, got %s", client.breaker.State())
+	}
+	clock.now = clock.now.Add(time.Minute)
+	if err := client.breaker.Allow(); err != nil {
+		t.Errorf("expected the next probe to be allowed, got %v", err)
+	}
+}
+
+func TestMakeRequestRecordsErrorMetrics(t *testing.T) {
+	server, _ := newStatusServer(t, 500, 429, 400)
+	client := newTestClient(3, 5)
+	client.name = "metrics_test"
+
+	if _, err := makeGET[MT5AccountResponse](context.Background(), client, server.URL, nil); err == nil {
+		t.Fatal("expected client error")
+	}
+
+	for _, reason := range []string{reasonServer, reasonRateLimited, reasonClient} {
+		if n := testutil.ToFloat64(requestErrors.WithLabelValues("metrics_test", reason)); n != 1 {
+			t.Errorf("expected 1 %s error, got %v", reason, n)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := &fixedTimeProvider{now: time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)}
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.timeProvider = clock
+
+	cb.Failure()
+	if cb.State() != CircuitClosed {
+		t.Fatalf("expected circuit closed below threshold, got %s", cb.State())
+	}
+	cb.Failure()
+	if cb.State() != CircuitOpen || cb.Allow() == nil {
+		t.Fatalf("expected circuit open at threshold, got %s", cb.State())
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if cb.State() != CircuitHalfOpen {
+		t.Fatalf("expected circuit half-open after open duration, got %s", cb.State())
+	}
+	if err := cb.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	if err := cb.Allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected only a single probe, got %v", err)
+	}
+
+	// A failed probe re-opens the circuit straight away
+	cb.Failure()
+	if cb.State() != CircuitOpen {
+		t.Fatalf("expected failed probe to re-open circuit, got %s", cb.State())
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if err := cb.Allow(); err != nil {
+		t.Fatalf("expected probe to be allowed, got %v", err)
+	}
+	cb.Success()
+	if cb.State() != CircuitClosed {
+		t.Errorf("expected successful probe to close circuit, got %s", cb.State())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{value: "", expected: 0},
+		{value: "5", expected: 5 * time.Second},
+		{value: "Tue, 02 Jan 2024 14:00:10 GMT", expected: 10 * time.Second},
+		{value: "Tue, 02 Jan 2024 13:59:00 GMT", expected: 0},
+		{value: "soon", expected: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.expected)
+		}
+	}
+}
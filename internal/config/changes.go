@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// Changes describes the differences between two configurations, one line per changed setting.
+// Credentials are not included, and settings only read on startup are reported as requiring a restart
+func Changes(old, new *Config) []string {
+	var changes []string
+	changed := func(name string, before, after any) {
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, fmt.Sprintf("%s: %+v -> %+v", name, before, after))
+		}
+	}
+	// secretChanged reports a change without the values, as they contain credentials
+	secretChanged := func(name string, before, after any) {
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, fmt.Sprintf("%s changed", name))
+		}
+	}
+	requiresRestart := func(name string, before, after any) {
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, fmt.Sprintf("%s changed, restart to apply", name))
+		}
+	}
+
+	changed("jobs.flatten_on_breach", old.Jobs.FlattenOnBreach, new.Jobs.FlattenOnBreach)
+	changed("jobs.warning_levels", old.Jobs.WarningLevels, new.Jobs.WarningLevels)
+	changed("jobs.equity_sample_interval", old.Jobs.EquitySampleInterval, new.Jobs.EquitySampleInterval)
+	changed("jobs.equity_sample_epsilon", old.Jobs.EquitySampleEpsilon, new.Jobs.EquitySampleEpsilon)
+
+	changed("notifications.channels", old.Notifications.Channels, new.Notifications.Channels)
+	secretChanged("notifications.telegram", old.Notifications.Telegram, new.Notifications.Telegram)
+	secretChanged("notifications.slack", old.Notifications.Slack, new.Notifications.Slack)
+	secretChanged("notifications.discord", old.Notifications.Discord, new.Notifications.Discord)
+	secretChanged("notifications.webhook", old.Notifications.Webhook, new.Notifications.Webhook)
+
+	oldProfiles, newProfiles := profilesByName(old.Profiles.Custom), profilesByName(new.Profiles.Custom)
+	for _, name := range sortedKeys(oldProfiles, newProfiles) {
+		before, existed := oldProfiles[name]
+		after, exists := newProfiles[name]
+		switch {
+		case !existed:
+			changes = append(changes, fmt.Sprintf("profile %s added: %+v", name, after))
+		case !exists:
+			changes = append(changes, fmt.Sprintf("profile %s removed", name))
+		default:
+			changed(fmt.Sprintf("profile %s", name), before, after)
+		}
+	}
+	for _, brokerType := range sortedKeys(old.Profiles.Defaults, new.Profiles.Defaults) {
+		changed(fmt.Sprintf("default profile of %s", brokerType), old.Profiles.Defaults[brokerType], new.Profiles.Defaults[brokerType])
+	}
+	for _, accountId := range sortedKeys(old.Rules, new.Rules) {
+		changed(fmt.Sprintf("rules of account %s", accountId), old.Rules[accountId], new.Rules[accountId])
+	}
+
+	requiresRestart("port", old.Port, new.Port)
+	requiresRestart("api_key", old.ApiKey, new.ApiKey)
+	requiresRestart("db", old.DB, new.DB)
+	requiresRestart("brokers", old.Brokers, new.Brokers)
+	requiresRestart("jobs.equity_check_interval", old.Jobs.EquityCheckInterval, new.Jobs.EquityCheckInterval)
+	requiresRestart("jobs.equity_check_concurrency", old.Jobs.EquityCheckConcurrency, new.Jobs.EquityCheckConcurrency)
+
+	return changes
+}
+
+func profilesByName(custom []ProfileConfig) map[string]ProfileConfig {
+	byName := make(map[string]ProfileConfig, len(custom))
+	for _, p := range custom {
+		byName[p.Name] = p
+	}
+	return byName
+}
+
+// sortedKeys returns the keys present in either map, sorted
+func sortedKeys[V any](a, b map[string]V) []string {
+	keys := slices.Collect(maps.Keys(a))
+	for k := range b {
+		if _, exists := a[k]; !exists {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
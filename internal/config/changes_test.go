@@ -0,0 +1,73 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	base := func() *Config {
+		cfg := defaultConfig()
+		cfg.ApiKey = "key"
+		cfg.Jobs.EquityCheckInterval = 60
+		cfg.Notifications.Channels = []string{"telegram"}
+		cfg.Notifications.Telegram = TelegramConfig{Token: "secret-token", ChatId: "1"}
+		cfg.Profiles.Custom = []ProfileConfig{{Name: "MYFIRM", DailyLossPercent: 4}}
+		cfg.Rules = map[string][]RuleConfig{"123": {{Type: "max_loss", LimitPercent: 8}}}
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		update func(cfg *Config)
+		want   []string
+	}{
+		{name: "Unchanged", update: func(*Config) {}},
+		{
+			name:   "Warning levels",
+			update: func(cfg *Config) { cfg.Jobs.WarningLevels = []float64{80} },
+			want:   []string{"jobs.warning_levels: [50 75 90] -> [80]"},
+		},
+		{
+			name: "Rules and profiles",
+			update: func(cfg *Config) {
+				cfg.Rules["123"][0].LimitPercent = 6
+				cfg.Profiles.Custom = append(cfg.Profiles.Custom, ProfileConfig{Name: "OTHER"})
+				cfg.Profiles.Defaults = map[string]string{"OANDA": "OTHER"}
+			},
+			want: []string{
+				"profile OTHER added: {Name:OTHER Timezone: DailyUpdateHour:0 DailyUpdateMinute:0 DailyLossPercent:0 MaxLossPercent:0 ProfitTargetPercent:0 MinTradingDays:0 DrawdownMode:}",
+				"default profile of OANDA:  -> OTHER",
+				"rules of account 123: [{Type:max_loss LimitPercent:8 TrailingMode:}] -> [{Type:max_loss LimitPercent:6 TrailingMode:}]",
+			},
+		},
+		{
+			name:   "Notifier credentials",
+			update: func(cfg *Config) { cfg.Notifications.Telegram.Token = "new-token" },
+			want:   []string{"notifications.telegram changed"},
+		},
+		{
+			name:   "Requires restart",
+			update: func(cfg *Config) { cfg.Jobs.EquityCheckInterval = 30 },
+			want:   []string{"jobs.equity_check_interval changed, restart to apply"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base()
+			tt.update(next)
+
+			got := Changes(base(), next)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changes() = %q, want %q", got, tt.want)
+			}
+			for _, change := range got {
+				if strings.Contains(change, "token") {
+					t.Errorf("change includes credentials: %s", change)
+				}
+			}
+		})
+	}
+}
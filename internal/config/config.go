@@ -1,171 +1,578 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// Built-in brokers, as listed in BROKERS
+const (
+	BrokerOanda = "oanda"
+	BrokerMT5   = "mt5"
+)
+
+// legacyBrokers are read from the environment even if they are not listed in BROKERS or the config file,
+// so deployments configured only by OANDA_API_KEY or MT5_API_KEY keep monitoring them
+var legacyBrokers = []string{BrokerOanda, BrokerMT5}
+
 type Config struct {
-	DB       PostgresConfig
-	Brokers  BrokersConfig
-	Jobs     JobsConfig
-	Telegram TelegramConfig
-	Port     string
-	ApiKey   string
+	DB            PostgresConfig      `yaml:"db"`
+	Brokers       BrokersConfig       `yaml:"brokers"`
+	Jobs          JobsConfig          `yaml:"jobs"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Profiles      ProfilesConfig      `yaml:"profiles"`
+	// Rules are risk rules attached to accounts by the broker's account ID, used by accounts without rules in account_rules_tb
+	Rules  map[string][]RuleConfig `yaml:"rules"`
+	Port   string                  `yaml:"port"`
+	ApiKey string                  `yaml:"api_key"`
 }
 
 type JobsConfig struct {
 	// Interval in seconds to check equity
-	EquityCheckInterval int
+	EquityCheckInterval int `yaml:"equity_check_interval"`
+	// FlattenOnBreach closes all positions and cancels all orders of an account when a risk rule is breached
+	FlattenOnBreach bool `yaml:"flatten_on_breach"`
+	// Default interval in seconds between intraday equity samples. 0 disables intraday sampling
+	EquitySampleInterval int `yaml:"equity_sample_interval"`
+	// Default minimum change in equity for an intraday sample to be recorded
+	EquitySampleEpsilon float64 `yaml:"equity_sample_epsilon"`
+	// Max number of accounts checked concurrently
+	EquityCheckConcurrency int `yaml:"equity_check_concurrency"`
+	// Percentages of a rule's allowed loss at which a warning is notified, in ascending order. Empty disables warnings
+	WarningLevels []float64 `yaml:"warning_levels"`
 }
 
 type PostgresConfig struct {
-	Username string
-	Password string
-	URL      string
-	Port     string
-	DBName   string
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	URL      string `yaml:"url"`
+	Port     string `yaml:"port"`
+	DBName   string `yaml:"name"`
+	// MigrateOnStartup applies any pending schema migrations when the service starts
+	MigrateOnStartup bool `yaml:"migrate_on_startup"`
+}
+
+type NotificationsConfig struct {
+	// Channels are the enabled notification backends, e.g. telegram, slack, discord, webhook
+	Channels []string       `yaml:"channels"`
+	Telegram TelegramConfig `yaml:"telegram"`
+	Slack    SlackConfig    `yaml:"slack"`
+	Discord  DiscordConfig  `yaml:"discord"`
+	Webhook  WebhookConfig  `yaml:"webhook"`
 }
 
 type TelegramConfig struct {
-	Token  string
-	ChatId string
+	Token  string `yaml:"token"`
+	ChatId string `yaml:"chat_id"`
+}
+
+type SlackConfig struct {
+	WebhookUrl string `yaml:"webhook_url"`
+}
+
+type DiscordConfig struct {
+	WebhookUrl string `yaml:"webhook_url"`
+}
+
+type WebhookConfig struct {
+	Url string `yaml:"url"`
 }
 
 type BrokersConfig struct {
-	Oanda OandaConfig
-	MT5   MT5Config
+	// Enabled are the brokers with a single connection to monitor, e.g. oanda, mt5
+	Enabled []string `yaml:"enabled"`
+	// Connections are named broker connections, for accounts spread across several logins or bridges of a broker type
+	Connections []ConnectionConfig `yaml:"connections"`
+	// Single are the single connections of brokers, keyed by the broker's name in Enabled, e.g. brokers.oanda
+	Single map[string]SingleConnectionConfig `yaml:",inline"`
+}
+
+// SingleConnectionConfig is the single connection of a broker, overridden by <NAME>_API_KEY, <NAME>_API_URL and <NAME>_<OPTION>
+type SingleConnectionConfig struct {
+	ApiKey  string            `yaml:"api_key"`
+	BaseUrl string            `yaml:"base_url"`
+	Options map[string]string `yaml:"options"`
+}
+
+// ConnectionConfig is a named connection to a broker API
+type ConnectionConfig struct {
+	Name string `yaml:"name"`
+	// Type is the broker type of the accounts served, e.g. OANDA or MT5_FTMO
+	Type string `yaml:"type"`
+	// Env is the broker environment of the accounts served, matching their broker_env. Empty serves any environment
+	Env     string `yaml:"env"`
+	ApiKey  string `yaml:"api_key"`
+	BaseUrl string `yaml:"base_url"`
+	// Accounts are the IDs of the accounts served. If set, the connection only serves these accounts
+	Accounts []string `yaml:"accounts"`
+	// Options are broker specific settings, as described by the schema of the broker type
+	Options map[string]string `yaml:"options"`
+	// EnvPrefix is the prefix of the environment variables the settings were read from, e.g. OANDA for OANDA_API_KEY.
+	// Empty for named connections, which are read from the variables of ConnectionEnv
+	EnvPrefix string `yaml:"-"`
 }
 
-type OandaConfig struct {
-	ApiKey  string
-	BaseUrl string
+// EnvVar returns the environment variable overriding a setting of the connection, e.g. api_key
+func (c ConnectionConfig) EnvVar(setting string) string {
+	if c.EnvPrefix != "" {
+		return envName(c.EnvPrefix, setting)
+	}
+	return ConnectionEnv(c.Name, setting)
+}
+
+// WithEnv returns the connection with the settings overridden by any environment variables that are set, so settings
+// missing from the config file, e.g. a required option of the broker type, can be set by the environment alone
+func (c ConnectionConfig) WithEnv(settings []string) ConnectionConfig {
+	options := make(map[string]string, len(c.Options))
+	for option, value := range c.Options {
+		options[option] = value
+	}
+	for _, setting := range settings {
+		switch setting {
+		case "api_key":
+			envString(c.EnvVar(setting), &c.ApiKey)
+		case "base_url":
+			envString(c.EnvVar(setting), &c.BaseUrl)
+		default:
+			if value := os.Getenv(c.EnvVar(setting)); value != "" {
+				options[setting] = value
+			}
+		}
+	}
+	if len(options) > 0 {
+		c.Options = options
+	}
+	return c
 }
 
-type MT5Config struct {
-	ApiKey  string
-	BaseUrl string
+type ProfilesConfig struct {
+	// Custom are prop firm profiles added to the built-in profiles, replacing any built-in profile of the same name
+	Custom []ProfileConfig `yaml:"custom"`
+	// Defaults maps a broker type to the profile used by its accounts without an assigned profile
+	Defaults map[string]string `yaml:"defaults"`
 }
 
+// ProfileConfig is a prop firm profile, see profiles.Profile
+type ProfileConfig struct {
+	Name                string  `yaml:"name"`
+	Timezone            string  `yaml:"timezone"`
+	DailyUpdateHour     int     `yaml:"daily_update_hour"`
+	DailyUpdateMinute   int     `yaml:"daily_update_minute"`
+	DailyLossPercent    float64 `yaml:"daily_loss_percent"`
+	MaxLossPercent      float64 `yaml:"max_loss_percent"`
+	ProfitTargetPercent float64 `yaml:"profit_target_percent"`
+	MinTradingDays      int     `yaml:"min_trading_days"`
+	DrawdownMode        string  `yaml:"drawdown_mode"`
+}
+
+// RuleConfig is a risk rule attached to an account, see rules.Config
+type RuleConfig struct {
+	Type         string  `yaml:"type"`
+	LimitPercent float64 `yaml:"limit_percent"`
+	TrailingMode string  `yaml:"trailing_mode"`
+}
+
+// LoadConfig loads the configuration file set by CONFIG_FILE, if any, and overrides its fields with any
+// environment variables that are set. Only the configuration of enabled features is required
 func LoadConfig() (*Config, error) {
 	// We validate the environment variables anyway
 	_ = godotenv.Load()
 
-	cfg := &Config{}
+	cfg := defaultConfig()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
 
-	if os.Getenv("PORT") == "" {
-		cfg.Port = "8001"
-	} else {
-		cfg.Port = os.Getenv("PORT")
+	cfg.Brokers.Enabled = cfg.Brokers.resolveEnabled()
+	cfg.Notifications.Channels = cfg.Notifications.resolveChannels()
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
 	}
 
-	if os.Getenv("INTERNAL_API_KEY") == "" {
-		return nil, fmt.Errorf("INTERNAL_API_KEY is required")
-	} else {
-		cfg.ApiKey = os.Getenv("INTERNAL_API_KEY")
+	return cfg, nil
+}
+
+// defaultConfig returns the configuration used for any fields that are not configured
+func defaultConfig() *Config {
+	return &Config{
+		Port: "8001",
+		DB: PostgresConfig{
+			MigrateOnStartup: true,
+		},
+		Jobs: JobsConfig{
+			// Flattening on breach closes live positions, so it must be explicitly enabled
+			FlattenOnBreach:        false,
+			EquityCheckConcurrency: 8,
+			WarningLevels:          []float64{50, 75, 90},
+		},
 	}
+}
 
-	eqInt, err := strconv.Atoi(os.Getenv("EQUITY_CHECK_INTERVAL"))
+// loadFile reads the YAML configuration file at path into the config. Unknown fields are rejected so typos are not ignored
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse EQUITY_CHECK_INTERVAL: %v", err)
+		return fmt.Errorf("failed to open config file: %v", err)
 	}
-	cfg.Jobs = JobsConfig{
-		EquityCheckInterval: eqInt,
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
 	}
+	return nil
+}
 
-	cfg.DB = PostgresConfig{
-		Username: os.Getenv("DB_USERNAME"),
-		Password: os.Getenv("DB_PASSWORD"),
-		URL:      os.Getenv("DB_URL"),
-		Port:     os.Getenv("DB_PORT"),
-		DBName:   os.Getenv("DB_NAME"),
+// applyEnv overrides the config with any environment variables that are set
+func (c *Config) applyEnv() error {
+	envString("PORT", &c.Port)
+	envString("INTERNAL_API_KEY", &c.ApiKey)
+
+	envString("DB_USERNAME", &c.DB.Username)
+	envString("DB_PASSWORD", &c.DB.Password)
+	envString("DB_URL", &c.DB.URL)
+	envString("DB_PORT", &c.DB.Port)
+	envString("DB_NAME", &c.DB.DBName)
+
+	envList("BROKERS", &c.Brokers.Enabled)
+	c.Brokers.applyEnv()
+
+	envList("NOTIFIERS", &c.Notifications.Channels)
+	envString("TELEGRAM_BOT_TOKEN", &c.Notifications.Telegram.Token)
+	envString("TELEGRAM_CHAT_ID", &c.Notifications.Telegram.ChatId)
+	envString("SLACK_WEBHOOK_URL", &c.Notifications.Slack.WebhookUrl)
+	envString("DISCORD_WEBHOOK_URL", &c.Notifications.Discord.WebhookUrl)
+	envString("WEBHOOK_URL", &c.Notifications.Webhook.Url)
+
+	if value := os.Getenv("WARNING_LEVELS"); value != "" {
+		levels, err := parseWarningLevels(value)
+		if err != nil {
+			return fmt.Errorf("failed to parse WARNING_LEVELS: %v", err)
+		}
+		c.Jobs.WarningLevels = levels
 	}
 
-	o := OandaConfig{
-		ApiKey:  os.Getenv("OANDA_API_KEY"),
-		BaseUrl: os.Getenv("OANDA_API_URL"),
+	return errors.Join(
+		envParse("EQUITY_CHECK_INTERVAL", &c.Jobs.EquityCheckInterval, strconv.Atoi),
+		envParse("FLATTEN_ON_BREACH", &c.Jobs.FlattenOnBreach, strconv.ParseBool),
+		envParse("EQUITY_SAMPLE_INTERVAL", &c.Jobs.EquitySampleInterval, strconv.Atoi),
+		envParse("EQUITY_SAMPLE_EPSILON", &c.Jobs.EquitySampleEpsilon, parseFloat),
+		envParse("EQUITY_CHECK_CONCURRENCY", &c.Jobs.EquityCheckConcurrency, strconv.Atoi),
+		envParse("MIGRATE_ON_STARTUP", &c.DB.MigrateOnStartup, strconv.ParseBool),
+	)
+}
+
+// ConnectionEnv returns the environment variable overriding a setting of a named broker connection,
+// e.g. BROKER_FTMO_LIVE_API_KEY for the api_key of the connection ftmo-live. base_url is overridden by BROKER_<NAME>_API_URL
+func ConnectionEnv(name, setting string) string {
+	return "BROKER_" + envName(name, setting)
+}
+
+// envString overrides the value with the environment variable, if set
+func envString(env string, value *string) {
+	if v := os.Getenv(env); v != "" {
+		*value = v
 	}
+}
 
-	m := MT5Config{
-		ApiKey:  os.Getenv("MT5_API_KEY"),
-		BaseUrl: os.Getenv("MT5_API_URL"),
+// envList overrides the values with the comma separated environment variable, if set
+func envList(env string, values *[]string) {
+	if v := os.Getenv(env); v != "" {
+		*values = strings.Split(v, ",")
 	}
+}
 
-	t := TelegramConfig{
-		Token:  os.Getenv("TELEGRAM_BOT_TOKEN"),
-		ChatId: os.Getenv("TELEGRAM_CHAT_ID"),
+// envParse overrides the value with the parsed environment variable, if set
+func envParse[T any](env string, value *T, parse func(string) (T, error)) error {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil
+	}
+	parsed, err := parse(v)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", env, err)
 	}
+	*value = parsed
+	return nil
+}
 
-	cfg.Telegram = t
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(value, 64)
+}
 
-	cfg.Brokers = BrokersConfig{
-		Oanda: o,
-		MT5:   m,
+func (c *Config) validate() error {
+	if c.ApiKey == "" {
+		return fmt.Errorf("api_key (INTERNAL_API_KEY) is required")
 	}
 
-	if err := cfg.DB.validate(); err != nil {
-		return nil, err
+	if err := c.DB.validate(); err != nil {
+		return err
 	}
 
-	if err := cfg.Brokers.validate(); err != nil {
-		return nil, err
+	if err := c.Brokers.validate(); err != nil {
+		return err
 	}
 
-	if err := cfg.Jobs.validate(); err != nil {
-		return nil, err
+	if err := c.Jobs.validate(); err != nil {
+		return err
 	}
 
-	if err := cfg.Telegram.validate(); err != nil {
-		return nil, err
+	if err := c.Notifications.validate(); err != nil {
+		return err
 	}
 
-	return cfg, nil
+	for accountId, accountRules := range c.Rules {
+		for _, rule := range accountRules {
+			if rule.Type == "" {
+				return fmt.Errorf("rules of account %s must have a type", accountId)
+			}
+		}
+	}
+
+	for _, p := range c.Profiles.Custom {
+		if p.Name == "" {
+			return fmt.Errorf("custom profiles must have a name")
+		}
+	}
+
+	return nil
 }
 
-// validate checks if the required environment variables are set
+// validate checks the database connection is configured
 func (p PostgresConfig) validate() error {
-	required := map[string]string{
-		"DB_USERNAME": p.Username,
-		"DB_PASSWORD": p.Password,
-		"DB_URL":      p.URL,
-		"DB_PORT":     p.Port,
+	required := []struct{ name, value string }{
+		{"db.username (DB_USERNAME)", p.Username},
+		{"db.password (DB_PASSWORD)", p.Password},
+		{"db.url (DB_URL)", p.URL},
+		{"db.port (DB_PORT)", p.Port},
 	}
 
-	for env, value := range required {
-		if value == "" {
-			return fmt.Errorf("%s is required", env)
+	for _, r := range required {
+		if r.value == "" {
+			return fmt.Errorf("%s is required", r.name)
 		}
 	}
 
 	return nil
 }
 
-func (b BrokersConfig) validate() error {
-	// Validate Oanda configuration
-	if b.Oanda.ApiKey == "" {
-		return fmt.Errorf("OANDA_API_KEY is required")
+// envName joins the prefix and setting into an environment variable name, upper cased with other characters replaced by _.
+// base_url is read from <PREFIX>_API_URL
+func envName(prefix, setting string) string {
+	if setting == "base_url" {
+		setting = "api_url"
+	}
+	toEnv := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return '_'
+		}, strings.ToUpper(s))
+	}
+	return fmt.Sprintf("%s_%s", toEnv(prefix), toEnv(setting))
+}
+
+// applyEnv overrides the broker connections with any environment variables that are set. Single connections are read
+// for the brokers listed in BROKERS or the config file, and the legacy brokers
+func (b *BrokersConfig) applyEnv() {
+	names := append(normaliseList(b.Enabled), legacyBrokers...)
+	for name := range b.Single {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		single := b.Single[name]
+		envString(envName(name, "api_key"), &single.ApiKey)
+		envString(envName(name, "base_url"), &single.BaseUrl)
+		for option, value := range single.Options {
+			envString(envName(name, option), &value)
+			single.Options[option] = value
+		}
+		if single.ApiKey == "" && single.BaseUrl == "" && len(single.Options) == 0 {
+			continue
+		}
+		if b.Single == nil {
+			b.Single = make(map[string]SingleConnectionConfig)
+		}
+		b.Single[name] = single
+	}
+
+	for i := range b.Connections {
+		conn := &b.Connections[i]
+		envString(conn.EnvVar("api_key"), &conn.ApiKey)
+		envString(conn.EnvVar("base_url"), &conn.BaseUrl)
+		for option, value := range conn.Options {
+			envString(conn.EnvVar(option), &value)
+			conn.Options[option] = value
+		}
+	}
+}
+
+// SingleConnection returns the single connection of the broker, with the prefix of the environment variables its settings are read from.
+// Its name and type are set by the broker registered under the name
+func (b BrokersConfig) SingleConnection(name string) ConnectionConfig {
+	single := b.Single[name]
+	return ConnectionConfig{
+		ApiKey:    single.ApiKey,
+		BaseUrl:   single.BaseUrl,
+		Options:   single.Options,
+		EnvPrefix: name,
 	}
-	if b.Oanda.BaseUrl == "" {
-		return fmt.Errorf("OANDA_API_URL is required")
+}
+
+// resolveEnabled returns the enabled brokers. If no brokers are listed, every broker with configuration present is enabled, in name order
+func (b BrokersConfig) resolveEnabled() []string {
+	if enabled := normaliseList(b.Enabled); len(enabled) > 0 {
+		return enabled
 	}
 
-	// Validate MT5 configuration
-	if b.MT5.ApiKey == "" {
-		return fmt.Errorf("MT5_API_KEY is required")
+	var enabled []string
+	for name, single := range b.Single {
+		if single.ApiKey != "" || single.BaseUrl != "" {
+			enabled = append(enabled, name)
+		}
 	}
-	if b.MT5.BaseUrl == "" {
-		return fmt.Errorf("MT5_API_URL is required")
+	slices.Sort(enabled)
+	return enabled
+}
+
+// validate checks the named connections. The brokers and the settings of each connection depend on the registered
+// broker types, and are checked when the connections are created
+func (b BrokersConfig) validate() error {
+	names := make(map[string]bool)
+	for i, conn := range b.Connections {
+		if conn.Name == "" {
+			return fmt.Errorf("brokers.connections[%d].name is required", i)
+		}
+		if names[conn.Name] {
+			return fmt.Errorf("duplicate broker connection: %s", conn.Name)
+		}
+		names[conn.Name] = true
+
+		// The remaining settings depend on the broker type, and are checked against its schema when the connection is created
+		if conn.Type == "" {
+			return fmt.Errorf("brokers.connections[%d].type is required for connection %s", i, conn.Name)
+		}
 	}
 
 	return nil
 }
 
 func (j JobsConfig) validate() error {
-	if j.EquityCheckInterval == 0 {
-		return fmt.Errorf("EQUITY_CHECK_INTERVAL is required and CANNOT be 0")
+	if j.EquityCheckInterval <= 0 {
+		return fmt.Errorf("jobs.equity_check_interval (EQUITY_CHECK_INTERVAL) is required and must be positive")
+	}
+	if j.EquitySampleInterval < 0 {
+		return fmt.Errorf("jobs.equity_sample_interval (EQUITY_SAMPLE_INTERVAL) CANNOT be negative")
+	}
+	if j.EquitySampleEpsilon < 0 {
+		return fmt.Errorf("jobs.equity_sample_epsilon (EQUITY_SAMPLE_EPSILON) CANNOT be negative")
+	}
+	if j.EquityCheckConcurrency < 1 {
+		return fmt.Errorf("jobs.equity_check_concurrency (EQUITY_CHECK_CONCURRENCY) must be at least 1")
+	}
+	for i, level := range j.WarningLevels {
+		if level <= 0 || level >= 100 {
+			return fmt.Errorf("jobs.warning_levels (WARNING_LEVELS) must be between 0 and 100, got %.2f", level)
+		}
+		if i > 0 && level <= j.WarningLevels[i-1] {
+			return fmt.Errorf("jobs.warning_levels (WARNING_LEVELS) must be in ascending order")
+		}
+	}
+
+	return nil
+}
+
+// parseWarningLevels parses the comma separated warning levels. 'none' disables warnings
+func parseWarningLevels(value string) ([]float64, error) {
+	if strings.ToLower(strings.TrimSpace(value)) == "none" {
+		return []float64{}, nil
+	}
+
+	var levels []float64
+	for _, v := range strings.Split(value, ",") {
+		level, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// normaliseList lower cases and trims the values, dropping any empty values
+func normaliseList(values []string) []string {
+	var normalised []string
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			normalised = append(normalised, v)
+		}
+	}
+	return normalised
+}
+
+// resolveChannels returns the enabled notification channels.
+// If no channels are listed, every channel with configuration present is enabled
+func (n NotificationsConfig) resolveChannels() []string {
+	if channels := normaliseList(n.Channels); len(channels) > 0 {
+		return channels
+	}
+
+	var channels []string
+	if n.Telegram.Token != "" || n.Telegram.ChatId != "" {
+		channels = append(channels, "telegram")
+	}
+	if n.Slack.WebhookUrl != "" {
+		channels = append(channels, "slack")
+	}
+	if n.Discord.WebhookUrl != "" {
+		channels = append(channels, "discord")
+	}
+	if n.Webhook.Url != "" {
+		channels = append(channels, "webhook")
+	}
+	return channels
+}
+
+// validate checks the configuration of the enabled notification channels only
+func (n NotificationsConfig) validate() error {
+	for _, channel := range n.Channels {
+		switch channel {
+		case "telegram":
+			if err := n.Telegram.validate(); err != nil {
+				return err
+			}
+		case "slack":
+			if n.Slack.WebhookUrl == "" {
+				return fmt.Errorf("notifications.slack.webhook_url (SLACK_WEBHOOK_URL) is required")
+			}
+		case "discord":
+			if n.Discord.WebhookUrl == "" {
+				return fmt.Errorf("notifications.discord.webhook_url (DISCORD_WEBHOOK_URL) is required")
+			}
+		case "webhook":
+			if n.Webhook.Url == "" {
+				return fmt.Errorf("notifications.webhook.url (WEBHOOK_URL) is required")
+			}
+		default:
+			return fmt.Errorf("unsupported notifier in NOTIFIERS: %s", channel)
+		}
 	}
 
 	return nil
@@ -173,10 +580,10 @@ func (j JobsConfig) validate() error {
 
 func (t TelegramConfig) validate() error {
 	if t.Token == "" {
-		return fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
+		return fmt.Errorf("notifications.telegram.token (TELEGRAM_BOT_TOKEN) is required")
 	}
 	if t.ChatId == "" {
-		return fmt.Errorf("TELEGRAM_CHAT_ID is required")
+		return fmt.Errorf("notifications.telegram.chat_id (TELEGRAM_CHAT_ID) is required")
 	}
 
 	return nil
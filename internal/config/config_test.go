@@ -0,0 +1,247 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfigFile = `
+api_key: file-key
+db:
+  username: user
+  password: pass
+  url: localhost
+  port: "5432"
+  name: risk
+brokers:
+  oanda:
+    api_key: oanda-key
+    base_url: https://api-fxpractice.oanda.com
+  connections:
+    - name: ftmo-live
+      type: MT5_FTMO
+      env: LIVE
+      base_url: https://bridge.example.com
+      accounts: ["555"]
+jobs:
+  equity_check_interval: 60
+  warning_levels: [80]
+notifications:
+  slack:
+    webhook_url: https://hooks.slack.com/services/x
+profiles:
+  custom:
+    - name: MYFIRM
+      timezone: America/New_York
+      daily_loss_percent: 4
+  defaults:
+    OANDA: MYFIRM
+rules:
+  "001-001-1":
+    - type: max_loss
+      limit_percent: 8
+`
+
+// envs are the environment variables read by LoadConfig, cleared so the tests don't depend on the environment
+var envs = []string{
+	"CONFIG_FILE", "PORT", "INTERNAL_API_KEY", "DB_USERNAME", "DB_PASSWORD", "DB_URL", "DB_PORT", "DB_NAME", "MIGRATE_ON_STARTUP",
+	"BROKERS", "OANDA_API_KEY", "OANDA_API_URL", "MT5_API_KEY", "MT5_API_URL", "IG_API_KEY", "BROKER_FTMO_LIVE_API_KEY", "BROKER_FTMO_LIVE_API_URL",
+	"NOTIFIERS", "TELEGRAM_BOT_TOKEN", "TELEGRAM_CHAT_ID", "SLACK_WEBHOOK_URL", "DISCORD_WEBHOOK_URL", "WEBHOOK_URL",
+	"EQUITY_CHECK_INTERVAL", "FLATTEN_ON_BREACH", "EQUITY_SAMPLE_INTERVAL", "EQUITY_SAMPLE_EPSILON", "EQUITY_CHECK_CONCURRENCY", "WARNING_LEVELS",
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfigFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "File only",
+			env:  map[string]string{"CONFIG_FILE": path, "BROKER_FTMO_LIVE_API_KEY": "ftmo-key"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.ApiKey != "file-key" || cfg.Port != "8001" || !cfg.DB.MigrateOnStartup || cfg.Jobs.FlattenOnBreach {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+				if !reflect.DeepEqual(cfg.Brokers.Enabled, []string{BrokerOanda}) {
+					t.Errorf("enabled brokers = %v, want only oanda", cfg.Brokers.Enabled)
+				}
+				if !reflect.DeepEqual(cfg.Notifications.Channels, []string{"slack"}) {
+					t.Errorf("channels = %v, want only slack", cfg.Notifications.Channels)
+				}
+				if !reflect.DeepEqual(cfg.Jobs.WarningLevels, []float64{80}) {
+					t.Errorf("warning levels = %v, want [80]", cfg.Jobs.WarningLevels)
+				}
+				if len(cfg.Profiles.Custom) != 1 || cfg.Profiles.Defaults["OANDA"] != "MYFIRM" {
+					t.Errorf("unexpected profiles %+v", cfg.Profiles)
+				}
+				if r := cfg.Rules["001-001-1"]; len(r) != 1 || r[0].Type != "max_loss" || r[0].LimitPercent != 8 {
+					t.Errorf("unexpected rules %+v", cfg.Rules)
+				}
+				if c := cfg.Brokers.Connections; len(c) != 1 || c[0].ApiKey != "ftmo-key" || c[0].Env != "LIVE" || len(c[0].Accounts) != 1 {
+					t.Errorf("unexpected connections %+v", c)
+				}
+			},
+		},
+		{
+			name: "Env overrides file",
+			env: map[string]string{
+				"CONFIG_FILE":              path,
+				"BROKER_FTMO_LIVE_API_KEY": "ftmo-key",
+				"INTERNAL_API_KEY":         "env-key",
+				"EQUITY_CHECK_INTERVAL":    "30",
+				"FLATTEN_ON_BREACH":        "true",
+				"WARNING_LEVELS":           "none",
+				"NOTIFIERS":                "Slack, ",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.ApiKey != "env-key" || cfg.Jobs.EquityCheckInterval != 30 || !cfg.Jobs.FlattenOnBreach {
+					t.Errorf("env not applied %+v", cfg)
+				}
+				if len(cfg.Jobs.WarningLevels) != 0 {
+					t.Errorf("expected warnings disabled, got %v", cfg.Jobs.WarningLevels)
+				}
+				if !reflect.DeepEqual(cfg.Notifications.Channels, []string{"slack"}) {
+					t.Errorf("channels = %v, want only slack", cfg.Notifications.Channels)
+				}
+			},
+		},
+		{
+			name: "Env only",
+			env: map[string]string{
+				"INTERNAL_API_KEY":      "env-key",
+				"DB_USERNAME":           "user",
+				"DB_PASSWORD":           "pass",
+				"DB_URL":                "localhost",
+				"DB_PORT":               "5432",
+				"EQUITY_CHECK_INTERVAL": "30",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if len(cfg.Brokers.Enabled) != 0 || len(cfg.Notifications.Channels) != 0 {
+					t.Errorf("expected nothing enabled, got brokers %v and channels %v", cfg.Brokers.Enabled, cfg.Notifications.Channels)
+				}
+				if !reflect.DeepEqual(cfg.Jobs.WarningLevels, []float64{50, 75, 90}) {
+					t.Errorf("warning levels = %v, want the defaults", cfg.Jobs.WarningLevels)
+				}
+			},
+		},
+		{
+			name: "Single connection from env",
+			env: map[string]string{
+				"CONFIG_FILE":              path,
+				"BROKER_FTMO_LIVE_API_KEY": "ftmo-key",
+				"BROKERS":                  "oanda,ig",
+				"IG_API_KEY":               "ig-key",
+				"MT5_API_KEY":              "mt5-key",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if !reflect.DeepEqual(cfg.Brokers.Enabled, []string{BrokerOanda, "ig"}) {
+					t.Errorf("enabled brokers = %v, want oanda and ig", cfg.Brokers.Enabled)
+				}
+				if cfg.Brokers.Single[BrokerOanda].ApiKey != "oanda-key" || cfg.Brokers.Single["ig"].ApiKey != "ig-key" || cfg.Brokers.Single[BrokerMT5].ApiKey != "mt5-key" {
+					t.Errorf("unexpected single connections %+v", cfg.Brokers.Single)
+				}
+				conn := cfg.Brokers.SingleConnection(BrokerMT5)
+				if env := conn.EnvVar("api_key"); env != "MT5_API_KEY" {
+					t.Errorf("api_key env = %s, want MT5_API_KEY", env)
+				}
+				if env := conn.EnvVar("base_url"); env != "MT5_API_URL" {
+					t.Errorf("base_url env = %s, want MT5_API_URL", env)
+				}
+				if env := cfg.Brokers.Connections[0].EnvVar("api_key"); env != "BROKER_FTMO_LIVE_API_KEY" {
+					t.Errorf("named connection api_key env = %s, want BROKER_FTMO_LIVE_API_KEY", env)
+				}
+			},
+		},
+		{
+			name:    "Enabled notifier not configured",
+			env:     map[string]string{"CONFIG_FILE": path, "BROKER_FTMO_LIVE_API_KEY": "ftmo-key", "NOTIFIERS": "telegram"},
+			wantErr: "TELEGRAM_BOT_TOKEN",
+		},
+		{
+			name:    "Invalid env value",
+			env:     map[string]string{"CONFIG_FILE": path, "EQUITY_CHECK_CONCURRENCY": "many"},
+			wantErr: "EQUITY_CHECK_CONCURRENCY",
+		},
+		{
+			name:    "Missing file",
+			env:     map[string]string{"CONFIG_FILE": filepath.Join(t.TempDir(), "missing.yaml")},
+			wantErr: "failed to open config file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range envs {
+				t.Setenv(env, "")
+			}
+			for env, value := range tt.env {
+				t.Setenv(env, value)
+			}
+
+			cfg, err := LoadConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("jobs:\n  equity_check_intreval: 60\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultConfig()
+	if err := cfg.loadFile(path); err == nil || !strings.Contains(err.Error(), "equity_check_intreval") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	for _, env := range envs {
+		t.Setenv(env, "")
+	}
+	t.Setenv("CONFIG_FILE", filepath.Join("..", "..", "config.example.yaml"))
+
+	if _, err := LoadConfig(); err != nil {
+		t.Errorf("example config is invalid: %v", err)
+	}
+}
+
+func TestConnectionEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting string
+		want    string
+	}{
+		{name: "ftmo-live", setting: "api_key", want: "BROKER_FTMO_LIVE_API_KEY"},
+		{name: "ftmo-live", setting: "base_url", want: "BROKER_FTMO_LIVE_API_URL"},
+		{name: "ig.demo", setting: "account-type", want: "BROKER_IG_DEMO_ACCOUNT_TYPE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := ConnectionEnv(tt.name, tt.setting); got != tt.want {
+				t.Errorf("ConnectionEnv(%q, %q) = %s, want %s", tt.name, tt.setting, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// fileState identifies a version of the config file
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, err
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// Watch reloads the configuration whenever the config file at path changes or a signal is received, until the context is done.
+// reload is called with the reloaded configuration, or the error if it failed to load or validate.
+// The file is polled every interval, and only signals are watched if path is empty
+func Watch(ctx context.Context, path string, interval time.Duration, signals <-chan os.Signal, reload func(*Config, error)) {
+	var last fileState
+	var poll <-chan time.Time
+	if path != "" {
+		last, _ = statFile(path)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if path != "" {
+				last, _ = statFile(path)
+			}
+			reload(LoadConfig())
+		case <-poll:
+			// A missing file may be being replaced, so it is checked again on the next poll
+			current, err := statFile(path)
+			if err != nil || (current.modTime.Equal(last.modTime) && current.size == last.size) {
+				continue
+			}
+			last = current
+			reload(LoadConfig())
+		}
+	}
+}
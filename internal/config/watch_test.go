@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	for _, env := range envs {
+		t.Setenv(env, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfigFile), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("BROKER_FTMO_LIVE_API_KEY", "ftmo-key")
+
+	type result struct {
+		cfg *Config
+		err error
+	}
+	reloads := make(chan result, 1)
+	signals := make(chan os.Signal, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, path, 10*time.Millisecond, signals, func(cfg *Config, err error) {
+		reloads <- result{cfg, err}
+	})
+
+	wait := func() result {
+		t.Helper()
+		select {
+		case r := <-reloads:
+			return r
+		case <-time.After(time.Second):
+			t.Fatal("expected the configuration to be reloaded")
+			return result{}
+		}
+	}
+
+	// Let the watcher record the initial state of the file
+	time.Sleep(50 * time.Millisecond)
+
+	// File changes are picked up by polling
+	updated := strings.Replace(testConfigFile, "warning_levels: [80]", "warning_levels: [60, 80]", 1)
+	if err := os.WriteFile(path, []byte(updated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if r := wait(); r.err != nil || len(r.cfg.Jobs.WarningLevels) != 2 {
+		t.Errorf("expected reloaded warning levels, got %+v", r)
+	}
+
+	// Reloads are also triggered by signals, and configuration that fails to validate is reported
+	t.Setenv("EQUITY_CHECK_INTERVAL", "0")
+	signals <- syscall.SIGHUP
+	if r := wait(); r.err == nil {
+		t.Errorf("expected invalid configuration to fail to reload")
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Modes of an account halt
+const (
+	// HaltUntilReset halts trading until the start of the next trading day
+	HaltUntilReset = "UNTIL_RESET"
+	// HaltUntilReleased halts trading until it is manually resumed
+	HaltUntilReleased = "UNTIL_RELEASED"
+)
+
+// Sources of an account halt
+const (
+	HaltSourceBreach = "BREACH"
+	HaltSourceManual = "MANUAL"
+)
+
+// AccountHalt is a halt of trading on a broker account
+type AccountHalt struct {
+	BrokerAccountID int64
+	Mode            string
+	Source          string
+	Reason          string
+	// Until is when the halt expires, zero if it is held until released
+	Until     time.Time
+	CreatedAt time.Time
+}
+
+// Active returns true if the halt has not expired at the given time
+func (h AccountHalt) Active(now time.Time) bool {
+	return h.Until.IsZero() || now.Before(h.Until)
+}
+
+// SetAccountHalt halts a broker account, replacing any existing halt
+func (c *Client) SetAccountHalt(ctx context.Context, halt AccountHalt) error {
+	query := `
+        INSERT INTO algotrade.account_halts_tb (broker_account_id, mode, source, reason, halted_until, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (broker_account_id) DO UPDATE
+        SET mode = EXCLUDED.mode, source = EXCLUDED.source, reason = EXCLUDED.reason,
+            halted_until = EXCLUDED.halted_until, created_at = EXCLUDED.created_at
+    `
+	var until sql.NullTime
+	if !halt.Until.IsZero() {
+		until = sql.NullTime{Time: halt.Until, Valid: true}
+	}
+	_, err := c.db.ExecContext(ctx, query, halt.BrokerAccountID, halt.Mode, halt.Source, halt.Reason, until, halt.CreatedAt)
+	return err
+}
+
+// DeleteAccountHalt releases the halt of a broker account, if any
+func (c *Client) DeleteAccountHalt(ctx context.Context, brokerID int64) error {
+	query := `DELETE FROM algotrade.account_halts_tb WHERE broker_account_id = $1`
+	_, err := c.db.ExecContext(ctx, query, brokerID)
+	return err
+}
+
+// GetAccountHalts returns the halts active at the given time, keyed by broker account ID
+func (c *Client) GetAccountHalts(ctx context.Context, now time.Time) (map[int64]AccountHalt, error) {
+	query := `
+        SELECT broker_account_id, mode, source, reason, halted_until, created_at
+        FROM algotrade.account_halts_tb
+        WHERE halted_until IS NULL OR halted_until > $1
+    `
+
+	rows, err := c.db.QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	halts := make(map[int64]AccountHalt)
+	for rows.Next() {
+		var h AccountHalt
+		var until sql.NullTime
+		if err := rows.Scan(&h.BrokerAccountID, &h.Mode, &h.Source, &h.Reason, &until, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		if until.Valid {
+			h.Until = until.Time.UTC()
+		}
+		h.CreatedAt = h.CreatedAt.UTC()
+		halts[h.BrokerAccountID] = h
+	}
+	return halts, rows.Err()
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"github.com/jwtly10/at4j-risk-manager/internal/broker"
 	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
 	"os"
 	"time"
 
@@ -50,6 +52,11 @@ func NewDBClient(db *sql.DB) *Client {
 	return &Client{db: db}
 }
 
+// Ping checks the database is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
 // GetActiveBrokers returns all active broker accounts and when equity was last tracked
 func (c *Client) GetActiveBrokers(ctx context.Context) ([]broker.BrokerWithLastEquity, error) {
 	query := `
@@ -68,6 +75,7 @@ func (c *Client) GetActiveBrokers(ctx context.Context) ([]broker.BrokerWithLastE
         LEFT JOIN (
             SELECT broker_account_id, MAX(created_at) as created_at
             FROM algotrade.equity_tracking_tb
+            WHERE sample_type = 'DAILY'
             GROUP BY broker_account_id
         ) e ON b.id = e.broker_account_id
         WHERE b.active = true
@@ -101,20 +109,31 @@ func (c *Client) GetActiveBrokers(ctx context.Context) ([]broker.BrokerWithLastE
 	return accounts, rows.Err()
 }
 
-// RecordEquity records the equity update for a broker account
-func (c *Client) RecordEquity(ctx context.Context, brokerID int64, equity float64) error {
+// Types of recorded equity samples
+const (
+	// SampleDaily is the equity snapshot taken at the daily reset, used as the day start equity
+	SampleDaily = "DAILY"
+	// SampleIntraday is an equity sample taken between daily resets
+	SampleIntraday = "INTRADAY"
+)
+
+// RecordEquity records the equity update for a broker account.
+// A delay greater than 0 marks the sample as late, recorded that long after it was due
+func (c *Client) RecordEquity(ctx context.Context, brokerID int64, equity float64, sampleType string, delay time.Duration) error {
 	query := `
         INSERT INTO algotrade.equity_tracking_tb 
-        (broker_account_id, equity)
-        VALUES ($1, $2)
+        (broker_account_id, equity, sample_type, late, delay_seconds)
+        VALUES ($1, $2, $3, $4, $5)
     `
-	_, err := c.db.ExecContext(ctx, query, brokerID, equity)
+	_, err := c.db.ExecContext(ctx, query, brokerID, equity, sampleType, delay > 0, int(delay.Seconds()))
 	return err
 }
 
 type EquityData struct {
 	Equity    float64
 	UpdatedAt time.Time
+	// Late is true if the equity was recorded after it was due, so is an approximation
+	Late bool
 }
 
 // GetLatestEquity returns the latest equity data for a broker account
@@ -139,3 +158,314 @@ func (c *Client) GetLatestEquity(ctx context.Context, brokerId string) (*EquityD
 
 	return &data, nil
 }
+
+// Supported equity history intervals
+const (
+	IntervalRaw    = "raw"
+	IntervalMinute = "1m"
+	IntervalHour   = "1h"
+	IntervalDay    = "1d"
+)
+
+// MaxHistoryBuckets is the max number of buckets returned by a single equity history query.
+// Raw history is paged, bucketed history must be requested for a range of at most this many intervals
+const MaxHistoryBuckets = 10000
+
+// intervalPrecision maps history intervals to postgres date_trunc precisions
+var intervalPrecision = map[string]string{
+	IntervalMinute: "minute",
+	IntervalHour:   "hour",
+	IntervalDay:    "day",
+}
+
+// intervalDuration maps history intervals to the duration of their buckets
+var intervalDuration = map[string]time.Duration{
+	IntervalMinute: time.Minute,
+	IntervalHour:   time.Hour,
+	IntervalDay:    24 * time.Hour,
+}
+
+// IsValidInterval checks if the interval is a supported equity history interval
+func IsValidInterval(interval string) bool {
+	_, exists := intervalPrecision[interval]
+	return exists || interval == IntervalRaw
+}
+
+// MaxHistoryRange returns the longest range that can be requested for the bucketed interval, 0 for raw history
+func MaxHistoryRange(interval string) time.Duration {
+	return intervalDuration[interval] * MaxHistoryBuckets
+}
+
+// EquityHistory is the equity history of a broker account
+type EquityHistory struct {
+	Buckets []EquityBucket
+	// Next is the time to request the rest of the range from, nil if the whole range was returned.
+	// Only set for raw history with more than MaxHistoryBuckets samples in the range
+	Next *time.Time
+}
+
+// EquityBucket is the OHLC of equity samples within a time bucket
+type EquityBucket struct {
+	// Time is the start of the bucket in UTC
+	Time    time.Time
+	Open    float64
+	High    float64
+	Low     float64
+	Close   float64
+	Samples int
+}
+
+// GetEquityHistory returns the equity of a broker account between from (inclusive) and to (exclusive),
+// bucketed by the given interval. Raw history returns each recorded sample as its own bucket, up to MaxHistoryBuckets samples
+func (c *Client) GetEquityHistory(ctx context.Context, brokerId string, from, to time.Time, interval string) (*EquityHistory, error) {
+	if interval == IntervalRaw {
+		return c.getRawEquityHistory(ctx, brokerId, from, to)
+	}
+
+	precision, exists := intervalPrecision[interval]
+	if !exists {
+		return nil, fmt.Errorf("unsupported interval: %s", interval)
+	}
+	if to.Sub(from) > MaxHistoryRange(interval) {
+		return nil, fmt.Errorf("range of %s exceeds %d buckets of interval %s", to.Sub(from), MaxHistoryBuckets, interval)
+	}
+
+	query := `
+        SELECT
+            date_trunc($4, et.created_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC' AS bucket,
+            (array_agg(et.equity ORDER BY et.created_at ASC))[1] AS open,
+            MAX(et.equity) AS high,
+            MIN(et.equity) AS low,
+            (array_agg(et.equity ORDER BY et.created_at DESC))[1] AS close,
+            COUNT(*) AS samples
+        FROM algotrade.equity_tracking_tb et
+        INNER JOIN algotrade.broker_accounts_tb ba ON et.broker_account_id = ba.id
+        WHERE ba.account_id = $1 AND et.created_at >= $2 AND et.created_at < $3
+        GROUP BY bucket
+        ORDER BY bucket ASC
+    `
+
+	rows, err := c.db.QueryContext(ctx, query, brokerId, from, to, precision)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching equity history: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []EquityBucket{}
+	for rows.Next() {
+		var b EquityBucket
+		if err := rows.Scan(&b.Time, &b.Open, &b.High, &b.Low, &b.Close, &b.Samples); err != nil {
+			return nil, err
+		}
+		b.Time = b.Time.UTC()
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &EquityHistory{Buckets: buckets}, nil
+}
+
+// getRawEquityHistory returns each recorded equity sample of a broker account between from and to, up to MaxHistoryBuckets samples.
+// One more sample is fetched to tell whether the range was cut short, and where to continue from
+func (c *Client) getRawEquityHistory(ctx context.Context, brokerId string, from, to time.Time) (*EquityHistory, error) {
+	query := `
+        SELECT et.equity, et.created_at
+        FROM algotrade.equity_tracking_tb et
+        INNER JOIN algotrade.broker_accounts_tb ba ON et.broker_account_id = ba.id
+        WHERE ba.account_id = $1 AND et.created_at >= $2 AND et.created_at < $3
+        ORDER BY et.created_at ASC
+        LIMIT $4
+    `
+
+	rows, err := c.db.QueryContext(ctx, query, brokerId, from, to, MaxHistoryBuckets+1)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching equity history: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []EquityBucket{}
+	for rows.Next() {
+		var equity float64
+		var createdAt time.Time
+		if err := rows.Scan(&equity, &createdAt); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, EquityBucket{
+			Time:    createdAt.UTC(),
+			Open:    equity,
+			High:    equity,
+			Low:     equity,
+			Close:   equity,
+			Samples: 1,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	history := &EquityHistory{Buckets: buckets}
+	if len(buckets) > MaxHistoryBuckets {
+		next := buckets[MaxHistoryBuckets].Time
+		history.Buckets = buckets[:MaxHistoryBuckets]
+		history.Next = &next
+	}
+	return history, nil
+}
+
+// GetDayStartEquity returns the first daily equity snapshot recorded for a broker account since the given time.
+// Returns nil if no snapshot has been recorded since then
+func (c *Client) GetDayStartEquity(ctx context.Context, brokerID int64, since time.Time) (*EquityData, error) {
+	query := `
+        SELECT equity, created_at, late
+        FROM algotrade.equity_tracking_tb
+        WHERE broker_account_id = $1 AND created_at >= $2 AND sample_type = 'DAILY'
+        ORDER BY created_at ASC
+        LIMIT 1
+    `
+
+	var data EquityData
+	err := c.db.QueryRowContext(ctx, query, brokerID, since).Scan(&data.Equity, &data.UpdatedAt, &data.Late)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching day start equity: %w", err)
+	}
+
+	return &data, nil
+}
+
+// HighWaterMarks are the highest equities recorded for a broker account, 0 if none have been recorded
+type HighWaterMarks struct {
+	// EndOfDay is the highest daily equity snapshot
+	EndOfDay float64
+	// Intraday is the highest equity across all samples
+	Intraday float64
+}
+
+// GetHighWaterMarks returns the highest equities recorded for a broker account
+func (c *Client) GetHighWaterMarks(ctx context.Context, brokerID int64) (HighWaterMarks, error) {
+	query := `
+        SELECT
+            COALESCE(MAX(equity) FILTER (WHERE sample_type = 'DAILY'), 0),
+            COALESCE(MAX(equity), 0)
+        FROM algotrade.equity_tracking_tb
+        WHERE broker_account_id = $1
+    `
+
+	var hwm HighWaterMarks
+	if err := c.db.QueryRowContext(ctx, query, brokerID).Scan(&hwm.EndOfDay, &hwm.Intraday); err != nil {
+		return HighWaterMarks{}, fmt.Errorf("error fetching high-water marks: %w", err)
+	}
+
+	return hwm, nil
+}
+
+// GetAccountRules returns the active risk rules attached to each broker account, keyed by broker account ID
+func (c *Client) GetAccountRules(ctx context.Context) (map[int64][]rules.Config, error) {
+	query := `
+        SELECT broker_account_id, rule_type, limit_percent, COALESCE(trailing_mode, '')
+        FROM algotrade.account_rules_tb
+        WHERE active = true
+        ORDER BY id
+    `
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accountRules := make(map[int64][]rules.Config)
+	for rows.Next() {
+		var brokerID int64
+		var cfg rules.Config
+		if err := rows.Scan(&brokerID, &cfg.Type, &cfg.LimitPercent, &cfg.TrailingMode); err != nil {
+			return nil, err
+		}
+		accountRules[brokerID] = append(accountRules[brokerID], cfg)
+	}
+	return accountRules, rows.Err()
+}
+
+// GetAccountProfiles returns the prop firm profile assigned to each broker account, keyed by broker account ID
+func (c *Client) GetAccountProfiles(ctx context.Context) (map[int64]profiles.Assignment, error) {
+	query := `
+        SELECT
+            broker_account_id,
+            profile_name,
+            timezone,
+            daily_update_hour,
+            daily_update_minute,
+            daily_loss_percent,
+            max_loss_percent,
+            profit_target_percent,
+            min_trading_days,
+            drawdown_mode
+        FROM algotrade.account_profiles_tb
+    `
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignments := make(map[int64]profiles.Assignment)
+	for rows.Next() {
+		var brokerID int64
+		var a profiles.Assignment
+		if err := rows.Scan(
+			&brokerID,
+			&a.ProfileName,
+			&a.Overrides.Timezone,
+			&a.Overrides.DailyUpdateHour,
+			&a.Overrides.DailyUpdateMinute,
+			&a.Overrides.DailyLossPercent,
+			&a.Overrides.MaxLossPercent,
+			&a.Overrides.ProfitTargetPercent,
+			&a.Overrides.MinTradingDays,
+			&a.Overrides.DrawdownMode,
+		); err != nil {
+			return nil, err
+		}
+		assignments[brokerID] = a
+	}
+	return assignments, rows.Err()
+}
+
+// SamplingConfig is the intraday equity sampling configuration of a broker account
+type SamplingConfig struct {
+	// Interval is the minimum time between intraday samples. 0 disables intraday sampling
+	Interval time.Duration
+	// Epsilon is the minimum change in equity since the last recorded sample for a new sample to be recorded
+	Epsilon float64
+}
+
+// GetAccountSampling returns the intraday sampling configuration overrides of each broker account, keyed by broker account ID
+func (c *Client) GetAccountSampling(ctx context.Context) (map[int64]SamplingConfig, error) {
+	query := `
+        SELECT broker_account_id, interval_seconds, epsilon
+        FROM algotrade.account_sampling_tb
+    `
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sampling := make(map[int64]SamplingConfig)
+	for rows.Next() {
+		var brokerID int64
+		var intervalSeconds int
+		var cfg SamplingConfig
+		if err := rows.Scan(&brokerID, &intervalSeconds, &cfg.Epsilon); err != nil {
+			return nil, err
+		}
+		cfg.Interval = time.Duration(intervalSeconds) * time.Second
+		sampling[brokerID] = cfg
+	}
+	return sampling, rows.Err()
+}
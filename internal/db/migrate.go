@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationsTable records the applied migrations of this service. It is kept separate from
+// the migration history of the Java services sharing the algotrade schema
+const migrationsTable = "algotrade.risk_manager_schema_migrations"
+
+// migrationLockID is the postgres advisory lock held while migrating, so concurrent instances don't race
+const migrationLockID = 7_411_020_013
+
+// Migration is a versioned schema change, with the SQL to apply and revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations from the directory of the file system. Files must be named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, with versions starting at 1 and without gaps
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has mismatched names: %s and %s", version, m.Name, match[2])
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", entry.Name(), err)
+		}
+		if match[3] == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must start at 1 without gaps, found %d at position %d", m.Version, i+1)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts the schema migrations of the service
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator returns a migrator for the migrations embedded in the service
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := LoadMigrations(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Version returns the latest applied migration version, 0 if none have been applied
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	return currentVersion(ctx, m.db)
+}
+
+// Up applies all pending migrations in order, returning the number applied.
+// Each migration runs in its own transaction, together with recording its version
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, migration := range m.migrations {
+		ok, err := m.step(ctx, func(tx *sql.Tx, version int) (bool, error) {
+			if version >= migration.Version {
+				return false, nil
+			}
+
+			logger.Infof("Applying migration %d_%s", migration.Version, migration.Name)
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return false, fmt.Errorf("error applying migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO `+migrationsTable+` (version, name) VALUES ($1, $2)`, migration.Version, migration.Name); err != nil {
+				return false, fmt.Errorf("error recording migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// Down reverts the latest applied migrations, up to the given number of steps, returning the number reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for reverted < steps {
+		ok, err := m.step(ctx, func(tx *sql.Tx, version int) (bool, error) {
+			if version == 0 {
+				return false, nil
+			}
+			if version > len(m.migrations) {
+				return false, fmt.Errorf("database is at version %d, newer than the latest known migration %d", version, len(m.migrations))
+			}
+
+			migration := m.migrations[version-1]
+			logger.Infof("Reverting migration %d_%s", migration.Version, migration.Name)
+			if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+				return false, fmt.Errorf("error reverting migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+migrationsTable+` WHERE version = $1`, migration.Version); err != nil {
+				return false, fmt.Errorf("error removing migration %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return reverted, err
+		}
+		if !ok {
+			break
+		}
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// step runs fn in a transaction holding the migration lock, with the current version read under the lock.
+// The transaction is committed only if fn made a change
+func (m *Migrator) step(ctx context.Context, fn func(tx *sql.Tx, version int) (bool, error)) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error starting migration transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, fmt.Errorf("error acquiring migration lock: %v", err)
+	}
+
+	version, err := currentVersion(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := fn(tx, version)
+	if err != nil || !changed {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing migration: %v", err)
+	}
+	return true, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	query := `
+        CREATE TABLE IF NOT EXISTS ` + migrationsTable + ` (
+            version    INT PRIMARY KEY,
+            name       VARCHAR(128) NOT NULL,
+            applied_at TIMESTAMPTZ  NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )
+    `
+	if _, err := m.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error creating migrations table: %v", err)
+	}
+	return nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func currentVersion(ctx context.Context, q querier) (int, error) {
+	var version int
+	if err := q.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+migrationsTable).Scan(&version); err != nil {
+		return 0, fmt.Errorf("error getting migration version: %v", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(embeddedMigrations, "migrations")
+	if err != nil {
+		t.Fatalf("invalid embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d", i, m.Version)
+		}
+	}
+	// Reverting the baseline must never drop the equity history
+	if strings.Contains(strings.ToUpper(migrations[0].Down), "DROP") {
+		t.Errorf("baseline migration down drops tables: %s", migrations[0].Down)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "Valid",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   file("CREATE TABLE a ()"),
+				"m/0001_a.down.sql": file("DROP TABLE a"),
+				"m/0002_b.up.sql":   file("CREATE TABLE b ()"),
+				"m/0002_b.down.sql": file("DROP TABLE b"),
+			},
+			want: 2,
+		},
+		{
+			name: "Missing down",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql": file("CREATE TABLE a ()"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Gap in versions",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   file("CREATE TABLE a ()"),
+				"m/0001_a.down.sql": file("DROP TABLE a"),
+				"m/0003_c.up.sql":   file("CREATE TABLE c ()"),
+				"m/0003_c.down.sql": file("DROP TABLE c"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Mismatched names",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   file("CREATE TABLE a ()"),
+				"m/0001_b.down.sql": file("DROP TABLE a"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid file name",
+			files: fstest.MapFS{
+				"m/create_a.sql": file("CREATE TABLE a ()"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := LoadMigrations(tt.files, "m")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && len(migrations) != tt.want {
+				t.Errorf("expected %d migrations, got %d", tt.want, len(migrations))
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+-- equity_tracking_tb predates the migrator and holds all equity history, so reverting the baseline leaves it in place.
+-- It has to be removed by hand if that is really intended
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS algotrade.account_rules_tb;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS algotrade.account_rules_tb (
+    id                SERIAL PRIMARY KEY,
+    broker_account_id BIGINT        NOT NULL REFERENCES algotrade.broker_accounts_tb (id),
+    -- daily_loss, max_loss, trailing_drawdown
+    rule_type         VARCHAR(32)   NOT NULL,
+    limit_percent     NUMERIC(7, 4) NOT NULL,
+    -- END_OF_DAY or INTRADAY, only used by trailing_drawdown
+    trailing_mode     VARCHAR(16),
+    active            BOOLEAN       NOT NULL DEFAULT true,
+    created_at        TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
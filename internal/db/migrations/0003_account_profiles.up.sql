@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS algotrade.account_profiles_tb (
+    broker_account_id     BIGINT PRIMARY KEY REFERENCES algotrade.broker_accounts_tb (id),
+    -- Name of a prop firm profile, e.g. FTMO, THE5ERS, FUNDEDNEXT
+    profile_name          VARCHAR(64)   NOT NULL,
+    -- Optional per account overrides of the profile parameters
+    timezone              VARCHAR(64),
+    daily_update_hour     INT,
+    daily_update_minute   INT,
+    daily_loss_percent    NUMERIC(7, 4),
+    max_loss_percent      NUMERIC(7, 4),
+    profit_target_percent NUMERIC(7, 4),
+    min_trading_days      INT,
+    -- STATIC, END_OF_DAY or INTRADAY
+    drawdown_mode         VARCHAR(16),
+    created_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at            TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS algotrade.account_sampling_tb;
+
+ALTER TABLE algotrade.equity_tracking_tb DROP COLUMN IF EXISTS sample_type;
@@ -0,0 +1,10 @@
+-- DAILY for the snapshot taken at the daily reset, INTRADAY for samples in between
+ALTER TABLE algotrade.equity_tracking_tb ADD COLUMN IF NOT EXISTS sample_type VARCHAR(16) NOT NULL DEFAULT 'DAILY';
+
+CREATE TABLE IF NOT EXISTS algotrade.account_sampling_tb (
+    broker_account_id BIGINT PRIMARY KEY REFERENCES algotrade.broker_accounts_tb (id),
+    -- Minimum seconds between intraday samples, 0 disables intraday sampling for the account
+    interval_seconds  INT            NOT NULL,
+    -- Minimum change in equity since the last recorded sample for a new sample to be recorded
+    epsilon           NUMERIC(19, 4) NOT NULL DEFAULT 0
+);
@@ -0,0 +1,2 @@
+ALTER TABLE algotrade.equity_tracking_tb DROP COLUMN IF EXISTS delay_seconds;
+ALTER TABLE algotrade.equity_tracking_tb DROP COLUMN IF EXISTS late;
@@ -0,0 +1,3 @@
+-- Daily snapshots missed during the daily update window are recorded late, with the delay from when they were due
+ALTER TABLE algotrade.equity_tracking_tb ADD COLUMN IF NOT EXISTS late BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE algotrade.equity_tracking_tb ADD COLUMN IF NOT EXISTS delay_seconds INT NOT NULL DEFAULT 0;
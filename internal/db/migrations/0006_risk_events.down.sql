@@ -0,0 +1 @@
+DROP TABLE IF EXISTS algotrade.risk_events_tb;
@@ -0,0 +1,18 @@
+-- Audit trail of every rule evaluation that crossed a warning level or breach limit
+CREATE TABLE IF NOT EXISTS algotrade.risk_events_tb (
+    id                BIGSERIAL PRIMARY KEY,
+    broker_account_id BIGINT         NOT NULL REFERENCES algotrade.broker_accounts_tb (id),
+    rule_type         VARCHAR(32)    NOT NULL,
+    -- WARNING or BREACH
+    level             VARCHAR(16)    NOT NULL,
+    -- Configured limit of the rule, as a percentage
+    threshold_percent NUMERIC(7, 4)  NOT NULL,
+    -- Equity level at which the rule is breached
+    limit_equity      NUMERIC(19, 4) NOT NULL,
+    observed_equity   NUMERIC(19, 4) NOT NULL,
+    -- NOTIFIED, FLATTENED or FLATTEN_FAILED
+    action            VARCHAR(32)    NOT NULL,
+    created_at        TIMESTAMPTZ    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS risk_events_account_created_idx ON algotrade.risk_events_tb (broker_account_id, created_at);
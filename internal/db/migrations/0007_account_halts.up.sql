@@ -0,0 +1,12 @@
+-- Trading halt (kill switch) state per broker account. An account is halted while it has a row that has not expired
+CREATE TABLE IF NOT EXISTS algotrade.account_halts_tb (
+    broker_account_id BIGINT PRIMARY KEY REFERENCES algotrade.broker_accounts_tb (id),
+    -- UNTIL_RESET or UNTIL_RELEASED
+    mode              VARCHAR(16) NOT NULL,
+    -- BREACH or MANUAL
+    source            VARCHAR(16) NOT NULL,
+    reason            TEXT        NOT NULL,
+    -- Start of the next trading day for UNTIL_RESET halts, NULL if held until manually released
+    halted_until      TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1 @@
+ALTER TABLE algotrade.risk_events_tb DROP COLUMN IF EXISTS warning_level;
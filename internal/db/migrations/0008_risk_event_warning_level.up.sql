@@ -0,0 +1,2 @@
+-- Warning level crossed, as a percentage of the rule's allowed loss. NULL for breaches
+ALTER TABLE algotrade.risk_events_tb ADD COLUMN IF NOT EXISTS warning_level NUMERIC(7, 4);
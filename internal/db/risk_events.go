@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Levels of a risk event
+const (
+	// RiskLevelWarning is recorded when equity crosses a warning level short of the rule's limit
+	RiskLevelWarning = "WARNING"
+	// RiskLevelBreach is recorded when equity crosses the rule's limit
+	RiskLevelBreach = "BREACH"
+)
+
+// Actions taken on a risk event
+const (
+	ActionNotified      = "NOTIFIED"
+	ActionFlattened     = "FLATTENED"
+	ActionFlattenFailed = "FLATTEN_FAILED"
+)
+
+// MaxRiskEvents is the max number of risk events returned by a single query
+const MaxRiskEvents = 1000
+
+// RiskEvent is a rule evaluation that crossed a warning level or breach limit
+type RiskEvent struct {
+	ID              int64
+	BrokerAccountID int64
+	// AccountID and BrokerName are the broker's identifiers of the account, populated when reading events
+	AccountID  string
+	BrokerName string
+	Rule       string
+	Level      string
+	// Threshold is the configured limit of the rule, as a percentage
+	Threshold float64
+	// Limit is the equity level at which the rule is breached
+	Limit  float64
+	Equity float64
+	// WarningLevel is the warning level crossed, as a percentage of the rule's allowed loss. 0 for breaches
+	WarningLevel float64
+	Action       string
+	CreatedAt    time.Time
+}
+
+// RiskEventFilter selects risk events. Zero values are not filtered on
+type RiskEventFilter struct {
+	AccountID string
+	Rule      string
+	Level     string
+	// From is inclusive and To is exclusive
+	From time.Time
+	To   time.Time
+	// Limit is the max number of events returned, newest first
+	Limit int
+}
+
+// RecordRiskEvent records a risk event for a broker account
+func (c *Client) RecordRiskEvent(ctx context.Context, event RiskEvent) error {
+	query := `
+        INSERT INTO algotrade.risk_events_tb
+        (broker_account_id, rule_type, level, threshold_percent, limit_equity, observed_equity, warning_level, action, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7::NUMERIC, 0), $8, $9)
+    `
+	_, err := c.db.ExecContext(ctx, query, event.BrokerAccountID, event.Rule, event.Level, event.Threshold, event.Limit, event.Equity, event.WarningLevel, event.Action, event.CreatedAt)
+	return err
+}
+
+// GetRiskEvents returns the risk events matching the filter, newest first
+func (c *Client) GetRiskEvents(ctx context.Context, filter RiskEventFilter) ([]RiskEvent, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.AccountID != "" {
+		where("ba.account_id = $%d", filter.AccountID)
+	}
+	if filter.Rule != "" {
+		where("re.rule_type = $%d", filter.Rule)
+	}
+	if filter.Level != "" {
+		where("re.level = $%d", filter.Level)
+	}
+	if !filter.From.IsZero() {
+		where("re.created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("re.created_at < $%d", filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxRiskEvents {
+		limit = MaxRiskEvents
+	}
+	args = append(args, limit)
+
+	query := `
+        SELECT re.id, re.broker_account_id, ba.account_id, ba.broker_name, re.rule_type, re.level,
+               re.threshold_percent, re.limit_equity, re.observed_equity, COALESCE(re.warning_level, 0), re.action, re.created_at
+        FROM algotrade.risk_events_tb re
+        INNER JOIN algotrade.broker_accounts_tb ba ON re.broker_account_id = ba.id
+    `
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY re.created_at DESC, re.id DESC LIMIT $%d", len(args))
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching risk events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []RiskEvent{}
+	for rows.Next() {
+		var e RiskEvent
+		if err := rows.Scan(&e.ID, &e.BrokerAccountID, &e.AccountID, &e.BrokerName, &e.Rule, &e.Level,
+			&e.Threshold, &e.Limit, &e.Equity, &e.WarningLevel, &e.Action, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = e.CreatedAt.UTC()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of events
+const (
+	// TypeEquity is published with the live equity of an account on every equity check
+	TypeEquity = "equity"
+	// TypeWarning is published when a rule crosses a warning level
+	TypeWarning = "warning"
+	// TypeBreach is published when a rule is breached
+	TypeBreach = "breach"
+)
+
+// Event is a live update published to subscribers
+type Event struct {
+	// ID increases with every event published by the hub
+	ID   int64
+	Type string
+	// AccountID is the broker's ID of the account the event is for
+	AccountID string
+	// Data is the payload of the event, serialised as JSON to clients
+	Data any
+}
+
+// EquityUpdate is the payload of an equity event
+type EquityUpdate struct {
+	AccountID  string    `json:"accountId"`
+	BrokerName string    `json:"brokerName"`
+	Equity     float64   `json:"equity"`
+	Time       time.Time `json:"time"`
+}
+
+// RiskUpdate is the payload of a warning or breach event
+type RiskUpdate struct {
+	AccountID  string `json:"accountId"`
+	BrokerName string `json:"brokerName"`
+	Rule       string `json:"rule"`
+	// Level is the warning level crossed, as a percentage of the allowed loss. 100 for breaches
+	Level     float64   `json:"level"`
+	Threshold float64   `json:"threshold"`
+	Limit     float64   `json:"limit"`
+	Equity    float64   `json:"equity"`
+	Time      time.Time `json:"time"`
+}
+
+// Hub fans out published events to subscribers. Publishing never blocks the publisher:
+// events are dropped for any subscriber whose buffer is full
+type Hub struct {
+	mu      sync.Mutex
+	lastID  int64
+	subs    map[chan Event]struct{}
+	dropped int64
+	closed  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on, buffering up to buffer events,
+// and a function to unsubscribe. The channel is closed when unsubscribed or when the hub is closed
+func (h *Hub) Subscribe(buffer int) (<-chan Event, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, buffer)
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	h.subs[ch] = struct{}{}
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, exists := h.subs[ch]; exists {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends an event to all subscribers, assigning its ID
+func (h *Hub) Publish(eventType, accountId string, data any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.lastID++
+	event := Event{ID: h.lastID, Type: eventType, AccountID: accountId, Data: data}
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+			h.dropped++
+		}
+	}
+}
+
+// Subscribers returns the number of current subscribers
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Dropped returns the number of events dropped for subscribers that were not keeping up
+func (h *Hub) Dropped() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dropped
+}
+
+// Close closes all subscriptions, so streams end and the server can shut down
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for ch := range h.subs {
+		close(ch)
+	}
+	h.subs = nil
+}
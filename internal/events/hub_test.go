@@ -0,0 +1,52 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+
+	fast, unsubscribeFast := h.Subscribe(4)
+	slow, _ := h.Subscribe(1)
+
+	for i := 0; i < 3; i++ {
+		h.Publish(TypeEquity, "123", EquityUpdate{AccountID: "123", Equity: float64(i)})
+	}
+
+	for i := 0; i < 3; i++ {
+		e := <-fast
+		if e.ID != int64(i+1) || e.Type != TypeEquity || e.Data.(EquityUpdate).Equity != float64(i) {
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+
+	if e := <-slow; e.ID != 1 {
+		t.Errorf("expected slow subscriber to receive the first event, got %+v", e)
+	}
+	if h.Dropped() != 2 {
+		t.Errorf("expected 2 events dropped for the slow subscriber, got %d", h.Dropped())
+	}
+
+	unsubscribeFast()
+	unsubscribeFast()
+	if _, open := <-fast; open {
+		t.Error("expected channel to be closed when unsubscribed")
+	}
+	if h.Subscribers() != 1 {
+		t.Errorf("expected 1 subscriber, got %d", h.Subscribers())
+	}
+
+	h.Close()
+	if _, open := <-slow; open {
+		t.Error("expected channel to be closed when the hub is closed")
+	}
+
+	// Subscribing and publishing after close are no-ops
+	h.Publish(TypeEquity, "123", nil)
+	late, unsubscribeLate := h.Subscribe(1)
+	unsubscribeLate()
+	if _, open := <-late; open {
+		t.Error("expected subscription to a closed hub to be closed")
+	}
+}
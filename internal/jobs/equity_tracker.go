@@ -2,59 +2,195 @@ package jobs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/events"
 	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
 	"github.com/jwtly10/at4j-risk-manager/internal/utils"
 	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+	"math"
+	"sync"
 	"time"
 )
 
+// flattenTimeout is the max time allowed to flatten an account after a breach
+const flattenTimeout = 30 * time.Second
+
+// minAccountTimeout is the lower bound of the deadline for checking a single account,
+// so very short check intervals still give brokers a chance to respond
+const minAccountTimeout = 500 * time.Millisecond
+
+// defaultMaxConcurrency is the number of accounts checked concurrently if not configured
+const defaultMaxConcurrency = 8
+
+// jobAlertKey groups alerts for failures of the whole equity check job
+const jobAlertKey = "job"
+
+// accountAlertKey groups alerts by broker account and error class, so a persistent
+// failure is only notified once rather than every tick
+func accountAlertKey(brokerID int64, class string) string {
+	return fmt.Sprintf("account:%d:%s", brokerID, class)
+}
+
+// brokerAlertKey groups alerts for a broker connection shared by all of its accounts
+func brokerAlertKey(connection string) string {
+	return fmt.Sprintf("broker:%s", connection)
+}
+
 type brokerRepository interface {
 	GetActiveBrokers(ctx context.Context) ([]broker.BrokerWithLastEquity, error)
-	RecordEquity(ctx context.Context, brokerID int64, equity float64) error
+	RecordEquity(ctx context.Context, brokerID int64, equity float64, sampleType string, delay time.Duration) error
+	GetDayStartEquity(ctx context.Context, brokerID int64, since time.Time) (*db.EquityData, error)
+	GetHighWaterMarks(ctx context.Context, brokerID int64) (db.HighWaterMarks, error)
+	GetAccountRules(ctx context.Context) (map[int64][]rules.Config, error)
+	GetAccountProfiles(ctx context.Context) (map[int64]profiles.Assignment, error)
+	GetAccountSampling(ctx context.Context) (map[int64]db.SamplingConfig, error)
+	RecordRiskEvent(ctx context.Context, event db.RiskEvent) error
+	GetRiskEvents(ctx context.Context, filter db.RiskEventFilter) ([]db.RiskEvent, error)
+	GetAccountHalts(ctx context.Context, now time.Time) (map[int64]db.AccountHalt, error)
+	SetAccountHalt(ctx context.Context, halt db.AccountHalt) error
+	DeleteAccountHalt(ctx context.Context, brokerID int64) error
+}
+
+// baseline is the cached recorded equity state of an account for a trading day
+type baseline struct {
+	dayStart time.Time
+	// dayStartEquity is 0 if no equity has been recorded for the trading day yet
+	dayStartEquity float64
+	// approximate is true if the day start equity was recorded late, after the daily update window
+	approximate    bool
+	highWaterMarks db.HighWaterMarks
+}
+
+// sample is the last intraday sampling state of an account
+type sample struct {
+	// checkedAt is the last time a sample was considered, whether or not it was recorded
+	checkedAt time.Time
+	// equity is the last equity recorded for the account
+	equity float64
+}
+
+// accountState is the in memory tracking state of a single broker account.
+// It is only accessed by the worker checking the account, and ticks never overlap
+type accountState struct {
+	// baseline caches the recorded equity state for the trading day, nil until loaded
+	baseline *baseline
+	// peak is the highest equity observed, for intraday trailing drawdown
+	peak float64
+	// breaches tracks the trading day start at which each rule was last breached,
+	// so a breach is only raised once per trading day
+	breaches map[string]time.Time
+	// warnings tracks the trading day start at which each rule crossed each warning level, keyed by warningKey
+	warnings map[string]time.Time
+	// eventsLoaded is true once the breaches and warnings already recorded for the trading day have been loaded,
+	// so a restart doesn't raise them again
+	eventsLoaded bool
+	// sample is the last intraday sample, nil until the first sample is considered
+	sample *sample
+	// pendingFlatten is true if the account has breached and still needs to be flattened
+	pendingFlatten bool
 }
 
-type BrokerTimeConfig struct {
-	Timezone          string
-	DailyUpdateHour   int // 0 - 23
-	DailyUpdateMinute int // 0 - 59
+// TrackerConfig configures how the equity tracker checks accounts
+type TrackerConfig struct {
+	CheckInterval time.Duration
+	// FlattenOnBreach closes all positions and cancels all orders of an account when a rule is breached
+	FlattenOnBreach bool
+	// Sampling is the default intraday sampling configuration, overridden per account
+	Sampling db.SamplingConfig
+	// MaxConcurrency is the max number of accounts checked concurrently
+	MaxConcurrency int
+	// WarningLevels are the percentages of a rule's allowed loss at which a warning is notified, in ascending order
+	WarningLevels []float64
+	// AccountRules are the configured rules of each account by the broker's account ID,
+	// used by accounts without rules in account_rules_tb
+	AccountRules map[string][]rules.Config
+}
+
+// TickStats are the runtime statistics of the equity check job
+type TickStats struct {
+	// StartedAt is when the tracker was started, zero if it has not been started
+	StartedAt time.Time
+	Ticks     int64
+	// Overruns is the number of ticks that took longer than the check interval
+	Overruns     int64
+	LastTickAt   time.Time
+	LastDuration time.Duration
+	// LastSuccessAt is the start of the last tick that completed without error
+	LastSuccessAt time.Time
 }
 
 type EquityTracker struct {
-	brokerRepo     brokerRepository
-	brokerConfigs  map[string]BrokerTimeConfig
-	notifier       *notifications.TelegramNotifier
-	brokerAdapters map[string]broker.BrokerAdapter
-	checkInterval  time.Duration
-	stop           chan struct{}
-	timeProvider   utils.TimeProvider
+	brokerRepo brokerRepository
+	profiles   profiles.Catalogue
+	// defaultProfiles maps a broker type to the profile used by accounts without an assigned profile
+	defaultProfiles map[string]string
+	notifier        notifications.Notifier
+	alerts          *notifications.AlertManager
+	// hub publishes live equity and risk updates to API streams
+	hub *events.Hub
+	// connections resolve the broker adapter of each account
+	connections  *broker.Connections
+	config       TrackerConfig
+	stop         chan struct{}
+	timeProvider utils.TimeProvider
+
+	mu sync.Mutex
+	// accounts tracks the state per broker account ID
+	accounts map[int64]*accountState
+	// statuses is the latest risk status per broker account_id, for the status API
+	statuses map[string]AccountStatus
+	// halts are the active trading halts per broker account ID, reloaded every tick
+	halts map[int64]db.AccountHalt
+	// pending is reloaded configuration to apply at the start of the next tick, nil if there is none
+	pending *pendingReload
+	stats   TickStats
 }
 
 func NewEquityTracker(
 	brokerRepo brokerRepository,
-	brokerConfigs map[string]BrokerTimeConfig,
-	notifier *notifications.TelegramNotifier,
-	brokerAdapters map[string]broker.BrokerAdapter,
-	checkInterval time.Duration,
+	catalogue profiles.Catalogue,
+	defaultProfiles map[string]string,
+	notifier notifications.Notifier,
+	hub *events.Hub,
+	connections *broker.Connections,
+	config TrackerConfig,
 ) *EquityTracker {
+	if config.MaxConcurrency <= 0 {
+		config.MaxConcurrency = defaultMaxConcurrency
+	}
+
 	return &EquityTracker{
-		brokerRepo:     brokerRepo,
-		brokerConfigs:  brokerConfigs,
-		notifier:       notifier,
-		brokerAdapters: brokerAdapters,
-		checkInterval:  checkInterval,
-		stop:           make(chan struct{}),
-		timeProvider:   utils.RealTimeProvider{},
+		brokerRepo:      brokerRepo,
+		profiles:        catalogue,
+		defaultProfiles: defaultProfiles,
+		notifier:        notifier,
+		alerts:          notifications.NewAlertManager(notifier, notifications.DefaultInitialBackoff, notifications.DefaultMaxBackoff),
+		hub:             hub,
+		connections:     connections,
+		config:          config,
+		stop:            make(chan struct{}),
+		timeProvider:    utils.RealTimeProvider{},
+		accounts:        make(map[int64]*accountState),
+		statuses:        make(map[string]AccountStatus),
+		halts:           make(map[int64]db.AccountHalt),
 	}
 }
 
 // Start starts the equity tracker with the given configuration
 func (et *EquityTracker) Start() error {
 
-	logger.Infof("Starting equity tracker with check interval '%v'", et.checkInterval)
+	logger.Infof("Starting equity tracker with check interval '%v' and max concurrency %d", et.config.CheckInterval, et.config.MaxConcurrency)
+
+	et.mu.Lock()
+	et.stats.StartedAt = time.Now()
+	et.mu.Unlock()
 
-	ticket := time.NewTicker(et.checkInterval)
+	ticket := time.NewTicker(et.config.CheckInterval)
 	defer ticket.Stop()
 
 	ctx := context.Background()
@@ -62,10 +198,15 @@ func (et *EquityTracker) Start() error {
 	for {
 		select {
 		case <-ticket.C:
-			if err := et.checkAndUpdateEquity(ctx); err != nil {
+			start := time.Now()
+			err := et.checkAndUpdateEquity(ctx)
+			if err != nil {
 				logger.Errorf("Error checking and updating equity: '%v'", err)
-				et.notifier.NotifyError("Error running update equity job", err)
+				et.alerts.Alert(jobAlertKey, "Error running update equity job", err)
+			} else {
+				et.alerts.Resolve(jobAlertKey, "Update equity job running successfully")
 			}
+			et.recordTick(start, time.Since(start), err)
 		case <-et.stop:
 			return nil
 		}
@@ -77,78 +218,617 @@ func (et *EquityTracker) Stop() {
 	close(et.stop)
 }
 
+// Stats returns the runtime statistics of the equity check job
+func (et *EquityTracker) Stats() TickStats {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	return et.stats
+}
+
+// recordTick updates the tick statistics, warning if the tick overran the check interval.
+// Ticks missed while a tick overruns are dropped rather than queued
+func (et *EquityTracker) recordTick(start time.Time, duration time.Duration, err error) {
+	et.mu.Lock()
+	et.stats.Ticks++
+	et.stats.LastTickAt = start
+	et.stats.LastDuration = duration
+	if err == nil {
+		et.stats.LastSuccessAt = start
+	}
+	overrun := duration > et.config.CheckInterval
+	if overrun {
+		et.stats.Overruns++
+	}
+	overruns := et.stats.Overruns
+	et.mu.Unlock()
+
+	checkDuration.Observe(duration.Seconds())
+
+	if overrun {
+		checkOverruns.Inc()
+		logger.Warnf("Equity check took %v, overrunning check interval %v (%d overruns total)", duration, et.config.CheckInterval, overruns)
+	}
+}
+
+// accountTimeout returns the deadline for checking a single account, derived from the check interval
+// so a slow broker cannot stall the next tick
+func (et *EquityTracker) accountTimeout() time.Duration {
+	return max(et.config.CheckInterval, minAccountTimeout)
+}
+
+// state returns the tracking state of the account, creating it if needed
+func (et *EquityTracker) state(brokerID int64) *accountState {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+
+	s, exists := et.accounts[brokerID]
+	if !exists {
+		s = &accountState{breaches: make(map[string]time.Time), warnings: make(map[string]time.Time)}
+		et.accounts[brokerID] = s
+	}
+	return s
+}
+
 // checkAndUpdateEquity checks and updates the equity for all active brokers
 // based on the configured check configurations
 func (et *EquityTracker) checkAndUpdateEquity(ctx context.Context) error {
 	logger.Infof("Running equity check job")
+	et.applyReload()
+
 	accounts, err := et.brokerRepo.GetActiveBrokers(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting all active brokers: %v", err)
 	}
 
 	logger.Debugf("Found %d active brokers", len(accounts))
+	et.pruneAccounts(accounts)
+
+	accountRules, err := et.brokerRepo.GetAccountRules(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting account rules: %v", err)
+	}
+
+	assignments, err := et.brokerRepo.GetAccountProfiles(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting account profiles: %v", err)
+	}
+
+	accountSampling, err := et.brokerRepo.GetAccountSampling(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting account sampling: %v", err)
+	}
+
+	halts, err := et.brokerRepo.GetAccountHalts(ctx, et.timeProvider.Now())
+	if err != nil {
+		return fmt.Errorf("error getting account halts: %v", err)
+	}
+	et.mu.Lock()
+	et.halts = halts
+	et.mu.Unlock()
+
+	et.reportCircuits()
+
+	tick := tickSettings{
+		rules:       accountRules,
+		assignments: assignments,
+		sampling:    accountSampling,
+	}
+
+	accountsCh := make(chan broker.BrokerWithLastEquity)
+	var wg sync.WaitGroup
+	for i := 0; i < min(et.config.MaxConcurrency, len(accounts)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for account := range accountsCh {
+				et.checkAccount(ctx, account, tick)
+			}
+		}()
+	}
 
 	for _, account := range accounts {
-		config, exists := et.brokerConfigs[account.BrokerType]
-		if !exists {
-			msg := fmt.Sprintf("No configuration found for broker type %s [broker: %s]. Skipping.", account.BrokerType, account.BrokerName)
-			logger.Warnf(msg)
-			et.notifier.NotifyError(msg, nil)
+		accountsCh <- account
+	}
+	close(accountsCh)
+	wg.Wait()
+
+	return nil
+}
+
+// reportCircuits alerts once per broker connection whose circuit breaker is open, and resolves the alert once it has closed again
+func (et *EquityTracker) reportCircuits() {
+	for _, conn := range et.connections.All() {
+		reporter, ok := conn.Adapter.(broker.CircuitReporter)
+		if !ok {
 			continue
 		}
 
-		adapter, exists := et.brokerAdapters[account.BrokerType]
-		if !exists {
-			msg := fmt.Sprintf("No adapter found for broker type %s [broker: %s]. Skipping.", account.BrokerType, account.BrokerName)
+		switch reporter.CircuitState() {
+		case broker.CircuitOpen:
+			msg := fmt.Sprintf("Broker connection %s unreachable, requests to all of its accounts are paused until it recovers", conn.Name)
 			logger.Warnf(msg)
-			et.notifier.NotifyError(msg, nil)
-			continue
+			et.alerts.Alert(brokerAlertKey(conn.Name), msg, broker.ErrCircuitOpen)
+		case broker.CircuitClosed:
+			et.alerts.Resolve(brokerAlertKey(conn.Name), fmt.Sprintf("Broker connection %s reachable again", conn.Name))
 		}
+	}
+}
 
-		location, err := time.LoadLocation(config.Timezone)
-		if err != nil {
-			msg := fmt.Sprintf("Error loading timezone %s: for broker type %v [broker: %s]: %v", config.Timezone, account.BrokerType, account.BrokerName, err)
-			logger.Errorf(msg)
-			et.notifier.NotifyError(msg, nil)
+// tickSettings are the per account settings loaded once per tick and shared by all workers
+type tickSettings struct {
+	rules       map[int64][]rules.Config
+	assignments map[int64]profiles.Assignment
+	sampling    map[int64]db.SamplingConfig
+}
+
+// checkAccount fetches the live equity of a single account, records any due equity snapshots
+// and evaluates its risk rules. Work for the account is bounded by the account timeout
+func (et *EquityTracker) checkAccount(ctx context.Context, account broker.BrokerWithLastEquity, tick tickSettings) {
+	state := et.state(account.ID)
+
+	profile, err := resolveProfile(et.profiles, et.defaultProfiles, account, tick.assignments)
+	if err != nil {
+		msg := fmt.Sprintf("No valid profile for broker type %s [broker: %s]. Skipping.", account.BrokerType, account.BrokerName)
+		logger.Warnf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "profile"), msg, err)
+		return
+	}
+	et.alerts.Resolve(accountAlertKey(account.ID, "profile"), fmt.Sprintf("Profile resolved for broker %s", account.BrokerName))
+
+	conn, err := et.connections.Resolve(account.BrokerAccount)
+	if err != nil {
+		msg := fmt.Sprintf("No broker connection for broker type %s [broker: %s]. Skipping.", account.BrokerType, account.BrokerName)
+		logger.Warnf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "adapter"), msg, err)
+		return
+	}
+	adapter := conn.Adapter
+	et.alerts.Resolve(accountAlertKey(account.ID, "adapter"), fmt.Sprintf("Broker connection %s found for broker %s", conn.Name, account.BrokerName))
+
+	location, err := time.LoadLocation(profile.Timezone)
+	if err != nil {
+		msg := fmt.Sprintf("Error loading timezone %s: for broker type %v [broker: %s]: %v", profile.Timezone, account.BrokerType, account.BrokerName, err)
+		logger.Errorf(msg)
+		et.alerts.Alert(accountAlertKey(account.ID, "timezone"), msg, nil)
+		return
+	}
+	et.alerts.Resolve(accountAlertKey(account.ID, "timezone"), fmt.Sprintf("Timezone loaded for broker %s", account.BrokerName))
+
+	now := et.timeProvider.Now().In(location)
+
+	accountCtx, cancel := context.WithTimeout(ctx, et.accountTimeout())
+	defer cancel()
+
+	equity, err := adapter.GetEquity(accountCtx, account.AccountID)
+	if errors.Is(err, broker.ErrCircuitOpen) {
+		// Reported once for the broker by reportCircuits rather than for every account
+		logger.Debugf("Skipping broker %s, %s unreachable", account.BrokerName, account.BrokerType)
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error getting equity for broker %s", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "equity"), msg, err)
+		return
+	}
+	et.alerts.Resolve(accountAlertKey(account.ID, "equity"), fmt.Sprintf("Getting equity for broker %s succeeded", account.BrokerName))
+
+	et.hub.Publish(events.TypeEquity, account.AccountID, events.EquityUpdate{
+		AccountID:  account.AccountID,
+		BrokerName: account.BrokerName,
+		Equity:     equity,
+		Time:       now.UTC(),
+	})
+
+	dayStart := tradingDayStart(now, profile.DailyUpdateHour, profile.DailyUpdateMinute)
+
+	if !hasDailySnapshot(account, dayStart) {
+		et.recordDailySnapshot(accountCtx, state, account, profile, equity, now, dayStart)
+	}
+
+	sampling, exists := tick.sampling[account.ID]
+	if !exists {
+		sampling = et.config.Sampling
+	}
+	et.sampleIntraday(accountCtx, state, account, sampling, equity, now)
+
+	ruleConfigs, exists := tick.rules[account.ID]
+	if !exists {
+		ruleConfigs = et.config.AccountRules[account.AccountID]
+	}
+	raised, warned, err := et.evaluateRules(accountCtx, state, account, profile, ruleConfigs, equity, now, dayStart)
+	if err != nil {
+		msg := fmt.Sprintf("Error evaluating rules for broker %s", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "rules"), msg, err)
+	} else {
+		et.alerts.Resolve(accountAlertKey(account.ID, "rules"), fmt.Sprintf("Rules evaluated for broker %s", account.BrokerName))
+	}
+
+	if len(raised) > 0 {
+		et.haltOnBreach(ctx, account, raised[0], dayStart.AddDate(0, 0, 1))
+	}
+
+	// Retried every tick until the account has been flattened successfully
+	action := db.ActionNotified
+	if state.pendingFlatten {
+		action = db.ActionFlattenFailed
+		if et.flatten(ctx, state, account, adapter) {
+			action = db.ActionFlattened
+		}
+	} else {
+		et.enforceHalt(ctx, account, adapter, now)
+	}
+
+	halted := 0.0
+	if _, ok := et.activeHalt(account.ID, now); ok {
+		halted = 1
+	}
+	accountHalted.WithLabelValues(account.AccountID, account.BrokerName).Set(halted)
+
+	var riskEvents []db.RiskEvent
+	for _, w := range warned {
+		event := riskEvent(w.Result, w.AccountID, w.Time, db.RiskLevelWarning, db.ActionNotified)
+		event.WarningLevel = w.Level
+		riskEvents = append(riskEvents, event)
+		et.hub.Publish(events.TypeWarning, account.AccountID, riskUpdate(account, w.Result, w.Level, w.Time))
+	}
+	for _, b := range raised {
+		riskEvents = append(riskEvents, riskEvent(b.Result, b.AccountID, b.Time, db.RiskLevelBreach, action))
+		et.hub.Publish(events.TypeBreach, account.AccountID, riskUpdate(account, b.Result, 100, b.Time))
+	}
+	et.recordRiskEvents(ctx, account, riskEvents)
+}
+
+// riskUpdate builds the stream payload of a rule evaluation that crossed a warning level or breach limit
+func riskUpdate(account broker.BrokerWithLastEquity, res rules.Result, level float64, t time.Time) events.RiskUpdate {
+	return events.RiskUpdate{
+		AccountID:  account.AccountID,
+		BrokerName: account.BrokerName,
+		Rule:       res.Rule,
+		Level:      level,
+		Threshold:  res.Threshold,
+		Limit:      res.Limit,
+		Equity:     res.Equity,
+		Time:       t.UTC(),
+	}
+}
+
+// riskEvent builds the risk event of a rule evaluation that crossed a warning level or breach limit
+func riskEvent(res rules.Result, brokerID int64, t time.Time, level, action string) db.RiskEvent {
+	return db.RiskEvent{
+		BrokerAccountID: brokerID,
+		Rule:            res.Rule,
+		Level:           level,
+		Threshold:       res.Threshold,
+		Limit:           res.Limit,
+		Equity:          res.Equity,
+		Action:          action,
+		CreatedAt:       t,
+	}
+}
+
+// recordRiskEvents records the warnings and breaches raised this tick in the risk event ledger
+func (et *EquityTracker) recordRiskEvents(ctx context.Context, account broker.BrokerWithLastEquity, events []db.RiskEvent) {
+	for _, event := range events {
+		if err := et.brokerRepo.RecordRiskEvent(ctx, event); err != nil {
+			msg := fmt.Sprintf("Error recording risk event for broker %s", account.BrokerName)
+			logger.Errorf("%s: %v", msg, err)
+			et.alerts.Alert(accountAlertKey(account.ID, "event"), msg, err)
 			continue
 		}
+		et.alerts.Resolve(accountAlertKey(account.ID, "event"), fmt.Sprintf("Recording risk events for broker %s succeeded", account.BrokerName))
+	}
+}
 
-		now := et.timeProvider.Now().In(location)
+// evaluateRules evaluates the risk rules for the account against the live equity, raising a breach for any rule
+// whose limit has been hit and a warning for any rule that has crossed a warning level. The newly raised breaches and warnings are returned
+func (et *EquityTracker) evaluateRules(ctx context.Context, state *accountState, account broker.BrokerWithLastEquity, profile profiles.Profile, ruleConfigs []rules.Config, equity float64, now, dayStart time.Time) ([]rules.Breach, []rules.Warning, error) {
+	// Evaluate whatever rules are valid, even if some are misconfigured
+	accountRules, buildErr := buildRules(profile, ruleConfigs)
 
-		if isUpdateTime(now, config.DailyUpdateHour, config.DailyUpdateMinute) {
-			// If there's NO last equity, we should always update it
-			if account.LastEquityUpdate != nil {
-				// Else handle the case when equity has already been tracked today
-				lastUpdateLocal := account.LastEquityUpdate.In(location)
+	b, err := et.getBaseline(ctx, state, account.ID, dayStart)
+	if err != nil {
+		return nil, nil, err
+	}
 
-				if isSameDay(now, lastUpdateLocal) {
-					logger.Debugf("LastEquity already updated for broker %s today", account.BrokerName)
-					continue
-				}
-			}
-			logger.Infof("Updating equity for broker %s", account.BrokerName)
+	state.peak = max(state.peak, b.highWaterMarks.Intraday, equity)
 
-			equity, err := adapter.GetEquity(ctx, account.AccountID)
-			if err != nil {
-				msg := fmt.Sprintf("Error getting equity for broker %s: %v", account.BrokerName, err)
-				logger.Errorf(msg)
-				et.notifier.NotifyError(msg, err)
-				continue
-			}
+	snapshot := rules.Snapshot{
+		AccountID:             account.ID,
+		BrokerName:            account.BrokerName,
+		Equity:                equity,
+		DayStartEquity:        b.dayStartEquity,
+		InitialBalance:        float64(account.InitialBalance),
+		HighWaterMark:         b.highWaterMarks.EndOfDay,
+		IntradayHighWaterMark: state.peak,
+		Time:                  now,
+	}
 
-			err = et.brokerRepo.RecordEquity(ctx, account.ID, equity)
-			if err != nil {
-				msg := fmt.Sprintf("Error recording equity for broker %s: %v", account.BrokerName, err)
-				logger.Errorf(msg)
-				et.notifier.NotifyError(msg, err)
-			}
+	et.updateStatus(state, account, profile, snapshot, accountRules, b)
+
+	et.loadRiskEvents(ctx, state, account, dayStart)
 
-			logger.Infof("LastEquity updated for broker %s: %.2f", account.BrokerName, equity)
-			et.notifier.Notify(fmt.Sprintf("Equity updated for broker %s: %.2f", account.BrokerName, equity))
+	var warned []rules.Warning
+	for _, warning := range rules.EvaluateWarnings(snapshot, accountRules, et.config.WarningLevels) {
+		if et.raiseWarning(state, warning, dayStart) {
+			warned = append(warned, warning)
 		}
 	}
 
-	return nil
+	var raised []rules.Breach
+	for _, breach := range rules.Evaluate(snapshot, accountRules) {
+		if et.raiseBreach(state, breach, dayStart) {
+			raised = append(raised, breach)
+		}
+	}
+
+	return raised, warned, buildErr
+}
+
+// warningKey identifies a warning level of a rule
+func warningKey(rule string, level float64) string {
+	return fmt.Sprintf("%s:%g", rule, level)
+}
+
+// raiseWarning notifies that a rule has crossed a warning level, at most once per level per trading day.
+// Lower levels crossed at the same time are not notified separately. Returns false if the level was already notified today
+func (et *EquityTracker) raiseWarning(state *accountState, warning rules.Warning, dayStart time.Time) bool {
+	if last, exists := state.warnings[warningKey(warning.Rule, warning.Level)]; exists && last.Equal(dayStart) {
+		return false
+	}
+	et.markWarned(state, warning.Rule, warning.Level, dayStart)
+
+	logger.Warnf("%s", warning)
+	et.notifier.Notify(notifications.SeverityWarning, fmt.Sprintf("RISK WARNING: %s", warning))
+	return true
+}
+
+// markWarned marks the warning level of the rule, and all levels below it, as notified for the trading day
+func (et *EquityTracker) markWarned(state *accountState, rule string, level float64, dayStart time.Time) {
+	for _, l := range et.config.WarningLevels {
+		if l <= level {
+			state.warnings[warningKey(rule, l)] = dayStart
+		}
+	}
+}
+
+// loadRiskEvents marks the breaches and warnings recorded for the account since the start of the trading day as raised,
+// once per account. If they can't be loaded, it is retried next check and they may be raised again.
+// A breach is not flattened again, any positions opened since are flattened by its halt
+func (et *EquityTracker) loadRiskEvents(ctx context.Context, state *accountState, account broker.BrokerWithLastEquity, dayStart time.Time) {
+	if state.eventsLoaded {
+		return
+	}
+
+	recorded, err := et.brokerRepo.GetRiskEvents(ctx, db.RiskEventFilter{AccountID: account.AccountID, From: dayStart})
+	if err != nil {
+		logger.Errorf("Error loading today's risk events for broker %s: %v", account.BrokerName, err)
+		return
+	}
+	for _, event := range recorded {
+		switch event.Level {
+		case db.RiskLevelBreach:
+			state.breaches[event.Rule] = dayStart
+		case db.RiskLevelWarning:
+			et.markWarned(state, event.Rule, event.WarningLevel, dayStart)
+		}
+	}
+	state.eventsLoaded = true
+}
+
+// recordDailySnapshot records the day start equity of the account for the trading day.
+// Snapshots recorded outside of the daily update window are marked late, and alerted on as the day's baseline is approximate
+func (et *EquityTracker) recordDailySnapshot(ctx context.Context, state *accountState, account broker.BrokerWithLastEquity, profile profiles.Profile, equity float64, now, dayStart time.Time) {
+	var delay time.Duration
+	if !isUpdateTime(now, profile.DailyUpdateHour, profile.DailyUpdateMinute) {
+		delay = now.Sub(dayStart).Round(time.Second)
+	}
+
+	logger.Infof("Updating equity for broker %s", account.BrokerName)
+
+	err := et.brokerRepo.RecordEquity(ctx, account.ID, equity, db.SampleDaily, delay)
+	if err != nil {
+		msg := fmt.Sprintf("Error recording equity for broker %s", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "record"), msg, err)
+		return
+	}
+	et.alerts.Resolve(accountAlertKey(account.ID, "record"), fmt.Sprintf("Recording equity for broker %s succeeded", account.BrokerName))
+
+	state.sample = &sample{checkedAt: now, equity: equity}
+
+	// The high-water marks are carried over from the cached baseline, or loaded if there is none yet (e.g. after a restart),
+	// so the snapshot never lowers the recorded peak. If they can't be loaded, the baseline is loaded when the rules are evaluated
+	hwm, err := et.carriedHighWaterMarks(ctx, state, account.ID)
+	if err != nil {
+		logger.Errorf("Error getting high-water marks for broker %s: %v", account.BrokerName, err)
+	} else {
+		state.baseline = &baseline{
+			dayStart:       dayStart,
+			dayStartEquity: equity,
+			approximate:    delay > 0,
+			highWaterMarks: db.HighWaterMarks{
+				EndOfDay: max(hwm.EndOfDay, equity),
+				Intraday: max(hwm.Intraday, equity),
+			},
+		}
+	}
+
+	if delay > 0 {
+		msg := fmt.Sprintf("Missed daily equity snapshot for broker %s, recorded %s late: %.2f. Day start equity for %s is approximate",
+			account.BrokerName, delay, equity, dayStart.Format("2006-01-02"))
+		logger.Warnf(msg)
+		et.notifier.Notify(notifications.SeverityWarning, msg)
+		return
+	}
+
+	logger.Infof("LastEquity updated for broker %s: %.2f", account.BrokerName, equity)
+	et.notifier.Notify(notifications.SeverityInfo, fmt.Sprintf("Equity updated for broker %s: %.2f", account.BrokerName, equity))
+}
+
+// carriedHighWaterMarks returns the high-water marks of the cached baseline, loading them if the account has no baseline yet
+func (et *EquityTracker) carriedHighWaterMarks(ctx context.Context, state *accountState, brokerID int64) (db.HighWaterMarks, error) {
+	if state.baseline != nil {
+		return state.baseline.highWaterMarks, nil
+	}
+	return et.brokerRepo.GetHighWaterMarks(ctx, brokerID)
+}
+
+// sampleIntraday records an intraday equity sample for the account at most once per sampling interval,
+// skipping the insert if equity has not moved more than the sampling epsilon since the last recorded sample
+func (et *EquityTracker) sampleIntraday(ctx context.Context, state *accountState, account broker.BrokerWithLastEquity, sampling db.SamplingConfig, equity float64, now time.Time) {
+	if sampling.Interval <= 0 {
+		return
+	}
+
+	last := state.sample
+	if last != nil && now.Sub(last.checkedAt) < sampling.Interval {
+		return
+	}
+
+	if last != nil && math.Abs(equity-last.equity) <= sampling.Epsilon {
+		logger.Debugf("Equity for broker %s unchanged within %.4f, skipping intraday sample", account.BrokerName, sampling.Epsilon)
+		state.sample = &sample{checkedAt: now, equity: last.equity}
+		return
+	}
+
+	if err := et.brokerRepo.RecordEquity(ctx, account.ID, equity, db.SampleIntraday, 0); err != nil {
+		msg := fmt.Sprintf("Error recording intraday equity for broker %s", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "sample"), msg, err)
+		return
+	}
+	et.alerts.Resolve(accountAlertKey(account.ID, "sample"), fmt.Sprintf("Recording intraday equity for broker %s succeeded", account.BrokerName))
+
+	logger.Debugf("Intraday equity sampled for broker %s: %.2f", account.BrokerName, equity)
+	state.sample = &sample{checkedAt: now, equity: equity}
+}
+
+// getBaseline returns the recorded equity state of the account for the trading day.
+// Values are cached for the trading day
+func (et *EquityTracker) getBaseline(ctx context.Context, state *accountState, brokerID int64, dayStart time.Time) (baseline, error) {
+	if state.baseline != nil && state.baseline.dayStart.Equal(dayStart) {
+		return *state.baseline, nil
+	}
+
+	data, err := et.brokerRepo.GetDayStartEquity(ctx, brokerID, dayStart)
+	if err != nil {
+		return baseline{}, fmt.Errorf("error getting day start equity: %v", err)
+	}
+
+	hwm, err := et.brokerRepo.GetHighWaterMarks(ctx, brokerID)
+	if err != nil {
+		return baseline{}, fmt.Errorf("error getting high-water marks: %v", err)
+	}
+
+	b := baseline{dayStart: dayStart, highWaterMarks: hwm}
+	if data != nil {
+		b.dayStartEquity = data.Equity
+		b.approximate = data.Late
+	}
+
+	state.baseline = &b
+	return b, nil
+}
+
+// raiseBreach alerts on a rule breach, at most once per rule per trading day.
+// Returns false if the breach was already raised today
+func (et *EquityTracker) raiseBreach(state *accountState, breach rules.Breach, dayStart time.Time) bool {
+	if last, exists := state.breaches[breach.Rule]; exists && last.Equal(dayStart) {
+		logger.Debugf("Rule '%s' already breached for broker %s today", breach.Rule, breach.BrokerName)
+		return false
+	}
+	state.breaches[breach.Rule] = dayStart
+
+	logger.Warnf("%s", breach)
+	et.notifier.Notify(notifications.SeverityCritical, fmt.Sprintf("RISK BREACH: %s", breach))
+
+	if et.config.FlattenOnBreach {
+		state.pendingFlatten = true
+	}
+	return true
+}
+
+// flatten cancels all pending orders and closes all open positions of the account, returning true if it succeeded
+func (et *EquityTracker) flatten(ctx context.Context, state *accountState, account broker.BrokerWithLastEquity, adapter broker.BrokerAdapter) bool {
+	logger.Warnf("Flattening all positions and orders for broker %s", account.BrokerName)
+
+	ctx, cancel := context.WithTimeout(ctx, flattenTimeout)
+	defer cancel()
+
+	if err := broker.Flatten(ctx, adapter, account.AccountID); err != nil {
+		msg := fmt.Sprintf("Error flattening broker %s after risk breach, retrying next check", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "flatten"), msg, err)
+		return false
+	}
+
+	state.pendingFlatten = false
+	et.alerts.Resolve(accountAlertKey(account.ID, "flatten"), fmt.Sprintf("Flattened broker %s", account.BrokerName))
+	logger.Infof("Flattened broker %s", account.BrokerName)
+	et.notifier.Notify(notifications.SeverityCritical, fmt.Sprintf("All positions closed and orders cancelled for broker %s after risk breach", account.BrokerName))
+	return true
+}
+
+// resolveProfile returns the profile of an account from the catalogue, with any per account overrides applied.
+// Accounts without an assigned profile use the default profile of their broker type
+func resolveProfile(catalogue profiles.Catalogue, defaultProfiles map[string]string, account broker.BrokerWithLastEquity, assignments map[int64]profiles.Assignment) (profiles.Profile, error) {
+	if a, exists := assignments[account.ID]; exists {
+		return catalogue.Resolve(a.ProfileName, a.Overrides)
+	}
+
+	name, exists := defaultProfiles[account.BrokerType]
+	if !exists {
+		return profiles.Profile{}, fmt.Errorf("no profile assigned and no default profile for broker type %s", account.BrokerType)
+	}
+
+	return catalogue.Resolve(name, profiles.Overrides{})
+}
+
+// buildRules builds the risk rules for an account. Rules attached to the account
+// take precedence over the profile rules of the same type
+func buildRules(profile profiles.Profile, ruleConfigs []rules.Config) ([]rules.Rule, error) {
+	var r []rules.Rule
+	var errs []error
+
+	attached := make(map[string]bool)
+	for _, cfg := range ruleConfigs {
+		rule, err := rules.NewRule(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		attached[rule.Name()] = true
+		r = append(r, rule)
+	}
+
+	for _, rule := range profile.Rules() {
+		if !attached[rule.Name()] {
+			r = append(r, rule)
+		}
+	}
+
+	return r, errors.Join(errs...)
+}
+
+// hasDailySnapshot checks if the daily equity snapshot has already been recorded for the trading day starting at dayStart
+func hasDailySnapshot(account broker.BrokerWithLastEquity, dayStart time.Time) bool {
+	// If there's NO last equity, we should always update it
+	if account.LastEquityUpdate == nil {
+		return false
+	}
+	return !account.LastEquityUpdate.Before(dayStart)
+}
+
+// tradingDayStart returns the most recent daily update time at or before the given time,
+// which marks the start of the current trading day
+func tradingDayStart(t time.Time, targetHour, targetMinute int) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), targetHour, targetMinute, 0, 0, t.Location())
+	if t.Before(start) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
 }
 
 // isUpdateTime checks if the given time is the target time to update equity for the broker
@@ -162,10 +842,3 @@ func isUpdateTime(t time.Time, targetHour, targetMinute int) bool {
 		currentMinute >= targetMinute &&
 		currentMinute < targetMinute+1
 }
-
-// isSameDay checks if two time instances are on the same day
-func isSameDay(t1, t2 time.Time) bool {
-	y1, m1, d1 := t1.Date()
-	y2, m2, d2 := t2.Date()
-	return y1 == y2 && m1 == m2 && d1 == d2
-}
@@ -1,7 +1,23 @@
 package jobs
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/broker/brokertest"
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/events"
+	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
 	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"math"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -55,6 +71,1122 @@ func TestIsUpdateTime(t *testing.T) {
 	}
 }
 
-// TODO: Test actually updating the equity
-// We may implement some logic in future to only track based on if
-// the equity has actually changed, so this is something to think about in future...
+func TestTradingDayStart(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		currentTime  time.Time
+		targetHour   int
+		targetMinute int
+		expected     time.Time
+	}{
+		{
+			name:         "After update time uses today",
+			currentTime:  time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC),
+			targetHour:   0,
+			targetMinute: 1,
+			expected:     time.Date(2024, 1, 2, 0, 1, 0, 0, time.UTC),
+		},
+		{
+			name:         "Exactly at update time uses today",
+			currentTime:  time.Date(2024, 1, 2, 0, 1, 0, 0, time.UTC),
+			targetHour:   0,
+			targetMinute: 1,
+			expected:     time.Date(2024, 1, 2, 0, 1, 0, 0, time.UTC),
+		},
+		{
+			name:         "Before update time uses yesterday",
+			currentTime:  time.Date(2024, 1, 2, 0, 0, 30, 0, time.UTC),
+			targetHour:   0,
+			targetMinute: 1,
+			expected:     time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC),
+		},
+		{
+			name:         "Respects location of given time",
+			currentTime:  time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC).In(prague),
+			targetHour:   0,
+			targetMinute: 1,
+			expected:     time.Date(2024, 1, 2, 0, 1, 0, 0, prague),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tradingDayStart(tt.currentTime, tt.targetHour, tt.targetMinute)
+			if !result.Equal(tt.expected) {
+				t.Errorf("tradingDayStart() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildRules(t *testing.T) {
+	profile := profiles.Profile{DailyLossPercent: 5}
+
+	t.Run("Uses profile rules", func(t *testing.T) {
+		r, err := buildRules(profile, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(r) != 1 || r[0] != (rules.DailyLossRule{MaxLossPercent: 5}) {
+			t.Errorf("expected profile daily loss rule, got %+v", r)
+		}
+	})
+
+	t.Run("Attached rule overrides default", func(t *testing.T) {
+		r, err := buildRules(profile, []rules.Config{
+			{Type: rules.DailyLoss, LimitPercent: 4},
+			{Type: rules.MaxLoss, LimitPercent: 10},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(r) != 2 || r[0] != (rules.DailyLossRule{MaxLossPercent: 4}) {
+			t.Errorf("expected attached rules only, got %+v", r)
+		}
+	})
+
+	t.Run("Invalid rule is skipped and reported", func(t *testing.T) {
+		r, err := buildRules(profile, []rules.Config{{Type: rules.TrailingDrawdown, LimitPercent: 5}})
+		if err == nil {
+			t.Errorf("expected error for invalid rule")
+		}
+		if len(r) != 1 {
+			t.Errorf("expected valid rules to still be built, got %+v", r)
+		}
+	})
+}
+
+type fixedTimeProvider struct {
+	now time.Time
+}
+
+func (f *fixedTimeProvider) Now() time.Time {
+	return f.now
+}
+
+type recordedEquity struct {
+	brokerID   int64
+	equity     float64
+	sampleType string
+	delay      time.Duration
+}
+
+type fakeRepo struct {
+	accounts    []broker.BrokerWithLastEquity
+	dayStart    map[int64]float64
+	hwm         map[int64]float64
+	rules       map[int64][]rules.Config
+	assignments map[int64]profiles.Assignment
+	sampling    map[int64]db.SamplingConfig
+
+	mu       sync.Mutex
+	recorded []recordedEquity
+	events   []db.RiskEvent
+	halts    map[int64]db.AccountHalt
+}
+
+func (f *fakeRepo) GetActiveBrokers(_ context.Context) ([]broker.BrokerWithLastEquity, error) {
+	return f.accounts, nil
+}
+
+func (f *fakeRepo) RecordEquity(_ context.Context, brokerID int64, equity float64, sampleType string, delay time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recorded = append(f.recorded, recordedEquity{brokerID: brokerID, equity: equity, sampleType: sampleType, delay: delay})
+	return nil
+}
+
+func (f *fakeRepo) RecordRiskEvent(_ context.Context, event db.RiskEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+	return nil
+}
+
+func (f *fakeRepo) GetRiskEvents(_ context.Context, filter db.RiskEventFilter) ([]db.RiskEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make(map[int64]string)
+	for _, a := range f.accounts {
+		ids[a.ID] = a.AccountID
+	}
+	var matched []db.RiskEvent
+	for _, e := range f.events {
+		if ids[e.BrokerAccountID] == filter.AccountID && (filter.Level == "" || e.Level == filter.Level) && !e.CreatedAt.Before(filter.From) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+func (f *fakeRepo) GetAccountHalts(_ context.Context, now time.Time) (map[int64]db.AccountHalt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	halts := make(map[int64]db.AccountHalt)
+	for id, h := range f.halts {
+		if h.Active(now) {
+			halts[id] = h
+		}
+	}
+	return halts, nil
+}
+
+func (f *fakeRepo) SetAccountHalt(_ context.Context, halt db.AccountHalt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.halts == nil {
+		f.halts = make(map[int64]db.AccountHalt)
+	}
+	f.halts[halt.BrokerAccountID] = halt
+	return nil
+}
+
+func (f *fakeRepo) DeleteAccountHalt(_ context.Context, brokerID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.halts, brokerID)
+	return nil
+}
+
+func (f *fakeRepo) GetDayStartEquity(_ context.Context, brokerID int64, _ time.Time) (*db.EquityData, error) {
+	e, exists := f.dayStart[brokerID]
+	if !exists {
+		return nil, nil
+	}
+	return &db.EquityData{Equity: e}, nil
+}
+
+func (f *fakeRepo) GetHighWaterMarks(_ context.Context, brokerID int64) (db.HighWaterMarks, error) {
+	return db.HighWaterMarks{EndOfDay: f.hwm[brokerID], Intraday: f.hwm[brokerID]}, nil
+}
+
+func (f *fakeRepo) GetAccountRules(_ context.Context) (map[int64][]rules.Config, error) {
+	return f.rules, nil
+}
+
+func (f *fakeRepo) GetAccountProfiles(_ context.Context) (map[int64]profiles.Assignment, error) {
+	return f.assignments, nil
+}
+
+func (f *fakeRepo) GetAccountSampling(_ context.Context) (map[int64]db.SamplingConfig, error) {
+	return f.sampling, nil
+}
+
+type fakeAdapter struct {
+	equity    float64
+	equityErr error
+	// delay is how long GetEquity takes to respond, unless the context is done first
+	delay   time.Duration
+	circuit broker.CircuitState
+
+	mu sync.Mutex
+	// positions are the open positions, closed when flattened
+	positions []broker.Position
+	// closeFailures is the number of times closing positions fails before it succeeds
+	closeFailures int
+	flattened     int
+	inFlight      int
+	maxInFlight   int
+}
+
+func (f *fakeAdapter) GetEquity(ctx context.Context, _ string) (float64, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	return f.equity, f.equityErr
+}
+
+func (f *fakeAdapter) CircuitState() broker.CircuitState {
+	return f.circuit
+}
+
+func (f *fakeAdapter) GetOpenPositions(_ context.Context, _ string) ([]broker.Position, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.positions, nil
+}
+
+func (f *fakeAdapter) GetPendingOrders(_ context.Context, _ string) ([]broker.Order, error) {
+	return nil, nil
+}
+
+func (f *fakeAdapter) CloseAllPositions(_ context.Context, _ string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closeFailures > 0 {
+		f.closeFailures--
+		return errors.New("market closed")
+	}
+	f.flattened++
+	f.positions = nil
+	return nil
+}
+
+func (f *fakeAdapter) CancelAllOrders(_ context.Context, _ string) error {
+	return nil
+}
+
+type notification struct {
+	severity notifications.Severity
+	message  string
+}
+
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []notification
+}
+
+func (f *fakeNotifier) Notify(severity notifications.Severity, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, notification{severity: severity, message: message})
+}
+
+func (f *fakeNotifier) NotifyError(message string, _ error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, notification{severity: notifications.SeverityError, message: message})
+}
+
+func (f *fakeNotifier) count(severity notifications.Severity) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, s := range f.sent {
+		if s.severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// countPrefix returns the number of notifications starting with the prefix
+func (f *fakeNotifier) countPrefix(prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, s := range f.sent {
+		if strings.HasPrefix(s.message, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// todaysSnapshot is the time of the FTMO daily snapshot for 2024-01-02, at 00:01:05 Prague time
+var todaysSnapshot = time.Date(2024, 1, 1, 23, 1, 5, 0, time.UTC)
+
+// testConnections returns a single MT5 FTMO connection serving every account
+func testConnections(adapter broker.BrokerAdapter) *broker.Connections {
+	connections, _ := broker.NewConnections(broker.Connection{Name: broker.MT5FTMO, Type: broker.MT5FTMO, Adapter: adapter})
+	return connections
+}
+
+func newTestTracker(repo *fakeRepo, adapter *fakeAdapter, notifier *fakeNotifier, now time.Time) *EquityTracker {
+	et := NewEquityTracker(
+		repo,
+		profiles.Presets(),
+		map[string]string{broker.MT5FTMO: profiles.FTMO},
+		notifier,
+		events.NewHub(),
+		testConnections(adapter),
+		TrackerConfig{CheckInterval: time.Second, FlattenOnBreach: true, WarningLevels: []float64{50, 75, 90}},
+	)
+	et.timeProvider = &fixedTimeProvider{now: now}
+	return et
+}
+
+func TestCheckAndUpdateEquityRecordsDailySnapshot(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}},
+		},
+	}
+	adapter := &fakeAdapter{equity: 100500}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 0, 1, 10, 0, prague))
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(repo.recorded) != 1 || repo.recorded[0] != (recordedEquity{brokerID: 1, equity: 100500, sampleType: db.SampleDaily}) {
+		t.Errorf("expected daily snapshot to be recorded, got %+v", repo.recorded)
+	}
+	if notifier.count(notifications.SeverityCritical) != 0 {
+		t.Errorf("expected no breach, got %+v", notifier.sent)
+	}
+}
+
+func TestCheckAndUpdateEquityCatchesUpMissedSnapshot(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	yesterday := time.Date(2024, 1, 1, 0, 1, 5, 0, prague)
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &yesterday},
+		},
+	}
+	adapter := &fakeAdapter{equity: 100500}
+	notifier := &fakeNotifier{}
+
+	// Service came back up at 03:31 Prague time, after the 00:01 update window
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 3, 31, 0, 0, prague))
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := recordedEquity{brokerID: 1, equity: 100500, sampleType: db.SampleDaily, delay: 3*time.Hour + 30*time.Minute}
+	if len(repo.recorded) != 1 || repo.recorded[0] != expected {
+		t.Fatalf("expected late daily snapshot %+v, got %+v", expected, repo.recorded)
+	}
+	if notifier.count(notifications.SeverityWarning) != 1 {
+		t.Errorf("expected approximate baseline warning, got %+v", notifier.sent)
+	}
+	if b := et.accounts[1].baseline; b == nil || !b.approximate || b.dayStartEquity != 100500 {
+		t.Errorf("expected approximate baseline to be cached, got %+v", b)
+	}
+}
+
+func TestCheckAndUpdateEquitySnapshotKeepsStoredHighWaterMarks(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	yesterday := time.Date(2024, 1, 1, 0, 1, 5, 0, prague)
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &yesterday},
+		},
+		// The account peaked before the restart and has drawn down since
+		hwm: map[int64]float64{1: 110000},
+	}
+	adapter := &fakeAdapter{equity: 100500}
+
+	// A fresh tracker after downtime, catching up the missed snapshot
+	et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 3, 31, 0, 0, prague))
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := db.HighWaterMarks{EndOfDay: 110000, Intraday: 110000}
+	if b := et.accounts[1].baseline; b == nil || b.highWaterMarks != expected || b.dayStartEquity != 100500 {
+		t.Errorf("expected stored high-water marks %+v to be kept, got %+v", expected, b)
+	}
+}
+
+func TestCheckAndUpdateEquityBreachFlattensOnce(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	// FTMO daily loss is 5% of the day start equity
+	adapter := &fakeAdapter{equity: 94900}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, prague))
+
+	for i := 0; i < 3; i++ {
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if len(repo.recorded) != 0 {
+		t.Errorf("expected no snapshot outside of update time, got %+v", repo.recorded)
+	}
+	if adapter.flattened != 1 {
+		t.Errorf("expected account to be flattened once, got %d", adapter.flattened)
+	}
+
+	breaches := 0
+	for _, n := range notifier.sent {
+		if strings.HasPrefix(n.message, "RISK BREACH") {
+			breaches++
+		}
+	}
+	if breaches != 1 {
+		t.Errorf("expected breach to be raised once, got %d: %+v", breaches, notifier.sent)
+	}
+
+	// Max loss has also used over 50% of its allowance, so is recorded as a warning
+	var breachEvents []db.RiskEvent
+	for _, e := range repo.events {
+		if e.Level == db.RiskLevelBreach {
+			breachEvents = append(breachEvents, e)
+		}
+	}
+	if len(breachEvents) != 1 {
+		t.Fatalf("expected a single breach event, got %+v", repo.events)
+	}
+	expected := db.RiskEvent{
+		BrokerAccountID: 1,
+		Rule:            rules.DailyLoss,
+		Level:           db.RiskLevelBreach,
+		Threshold:       5,
+		Limit:           95000,
+		Equity:          94900,
+		Action:          db.ActionFlattened,
+	}
+	event := breachEvents[0]
+	if !event.CreatedAt.Equal(time.Date(2024, 1, 2, 14, 0, 0, 0, prague)) {
+		t.Errorf("risk event time = %v, want breach time", event.CreatedAt)
+	}
+	event.CreatedAt = time.Time{}
+	if event != expected {
+		t.Errorf("risk event = %+v, want %+v", event, expected)
+	}
+}
+
+func TestCheckAndUpdateEquityRetriesFailedFlatten(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	adapter := &fakeAdapter{equity: 94900, closeFailures: 1}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, prague))
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if adapter.flattened != 0 || !et.accounts[1].pendingFlatten {
+		t.Fatalf("expected the failed flatten to be pending, flattened %d times", adapter.flattened)
+	}
+
+	var actions []string
+	for _, e := range repo.events {
+		if e.Level == db.RiskLevelBreach {
+			actions = append(actions, e.Action)
+		}
+	}
+	if len(actions) != 1 || actions[0] != db.ActionFlattenFailed {
+		t.Errorf("expected the breach to be recorded as %s, got %v", db.ActionFlattenFailed, actions)
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if adapter.flattened != 1 || et.accounts[1].pendingFlatten {
+		t.Errorf("expected the flatten to be retried once, flattened %d times", adapter.flattened)
+	}
+}
+
+func TestCheckAndUpdateEquityThrottlesBrokerErrors(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+	}
+	adapter := &fakeAdapter{equityErr: errors.New("connection refused")}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+
+	for i := 0; i < 5; i++ {
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if notifier.count(notifications.SeverityError) != 1 {
+		t.Errorf("expected a single error notification, got %+v", notifier.sent)
+	}
+
+	adapter.equityErr = nil
+	adapter.equity = 100000
+	for i := 0; i < 2; i++ {
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if notifier.count(notifications.SeverityInfo) != 1 || !strings.HasPrefix(notifier.sent[len(notifier.sent)-1].message, "RESOLVED") {
+		t.Errorf("expected a single resolved notification, got %+v", notifier.sent)
+	}
+}
+
+func TestCheckAndUpdateEquitySamplesOnlyChanges(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		sampling: map[int64]db.SamplingConfig{1: {Interval: time.Minute, Epsilon: 1}},
+	}
+	adapter := &fakeAdapter{equity: 100000}
+	notifier := &fakeNotifier{}
+
+	clock := &fixedTimeProvider{now: time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)}
+	et := newTestTracker(repo, adapter, notifier, clock.now)
+	et.timeProvider = clock
+
+	// Each step is a tick 30 seconds apart, with the equity observed at that tick
+	steps := []float64{100000, 100200, 100500, 100000, 100500.5, 99000}
+	for _, equity := range steps {
+		adapter.equity = equity
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		clock.now = clock.now.Add(30 * time.Second)
+	}
+
+	// 0s: first sample recorded. 30s: within interval. 60s: moved, recorded.
+	// 90s: within interval. 120s: within epsilon of last recorded, skipped. 150s: within interval
+	expected := []recordedEquity{
+		{brokerID: 1, equity: 100000, sampleType: db.SampleIntraday},
+		{brokerID: 1, equity: 100500, sampleType: db.SampleIntraday},
+	}
+	if len(repo.recorded) != len(expected) {
+		t.Fatalf("expected %d samples, got %+v", len(expected), repo.recorded)
+	}
+	for i := range expected {
+		if repo.recorded[i] != expected[i] {
+			t.Errorf("sample %d = %+v, want %+v", i, repo.recorded[i], expected[i])
+		}
+	}
+
+	// The next tick after the skipped sample is a full interval later, and has moved
+	adapter.equity = 99000
+	clock.now = clock.now.Add(30 * time.Second)
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repo.recorded) != 3 || repo.recorded[2].equity != 99000 {
+		t.Errorf("expected moved equity to be sampled, got %+v", repo.recorded)
+	}
+}
+
+func TestCheckAndUpdateEquityBoundsConcurrency(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{}
+	for i := int64(1); i <= 10; i++ {
+		repo.accounts = append(repo.accounts, broker.BrokerWithLastEquity{
+			BrokerAccount:    broker.BrokerAccount{ID: i, BrokerName: fmt.Sprintf("ftmo-%d", i), BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000},
+			LastEquityUpdate: &todaysSnapshot,
+		})
+	}
+	adapter := &fakeAdapter{equity: 100000, delay: 20 * time.Millisecond}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+	et.config.MaxConcurrency = 3
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if adapter.maxInFlight < 2 || adapter.maxInFlight > 3 {
+		t.Errorf("expected between 2 and 3 concurrent requests, got %d", adapter.maxInFlight)
+	}
+	if len(et.accounts) != 10 {
+		t.Errorf("expected all 10 accounts to be checked, got %d", len(et.accounts))
+	}
+}
+
+func TestCheckAndUpdateEquityTimesOutSlowBroker(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+	}
+	adapter := &fakeAdapter{equity: 100000, delay: time.Minute}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+
+	start := time.Now()
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected tick to be bounded by the account timeout, took %v", elapsed)
+	}
+	if notifier.count(notifications.SeverityError) != 1 {
+		t.Errorf("expected timeout to be alerted, got %+v", notifier.sent)
+	}
+}
+
+func TestRecordTickCountsOverruns(t *testing.T) {
+	logger.InitLogger()
+
+	et := newTestTracker(&fakeRepo{}, &fakeAdapter{}, &fakeNotifier{}, time.Now())
+	start := time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)
+
+	et.recordTick(start, 500*time.Millisecond, nil)
+	et.recordTick(start.Add(time.Second), 1500*time.Millisecond, errors.New("db unavailable"))
+
+	stats := et.Stats()
+	if stats.Ticks != 2 || stats.Overruns != 1 {
+		t.Errorf("expected 2 ticks and 1 overrun, got %+v", stats)
+	}
+	if stats.LastDuration != 1500*time.Millisecond || !stats.LastTickAt.Equal(start.Add(time.Second)) {
+		t.Errorf("expected last tick to be recorded, got %+v", stats)
+	}
+	if !stats.LastSuccessAt.Equal(start) {
+		t.Errorf("expected failed tick not to count as a success, got %+v", stats)
+	}
+}
+
+func TestCheckAndUpdateEquityReportsOpenCircuitOnce(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{}
+	for i := int64(1); i <= 3; i++ {
+		repo.accounts = append(repo.accounts, broker.BrokerWithLastEquity{
+			BrokerAccount:    broker.BrokerAccount{ID: i, BrokerName: fmt.Sprintf("ftmo-%d", i), BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000},
+			LastEquityUpdate: &todaysSnapshot,
+		})
+	}
+	adapter := &fakeAdapter{equityErr: broker.ErrCircuitOpen, circuit: broker.CircuitOpen}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+
+	for i := 0; i < 3; i++ {
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if notifier.count(notifications.SeverityError) != 1 {
+		t.Errorf("expected a single unreachable notification for the broker, got %+v", notifier.sent)
+	}
+
+	adapter.equityErr = nil
+	adapter.equity = 100000
+	adapter.circuit = broker.CircuitClosed
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if notifier.count(notifications.SeverityInfo) != 1 {
+		t.Errorf("expected a single resolved notification for the broker, got %+v", notifier.sent)
+	}
+}
+
+func TestCheckAndUpdateEquityFlattensMockBroker(t *testing.T) {
+	logger.InitLogger()
+
+	server := brokertest.NewServer("test-key")
+	defer server.Close()
+
+	// FTMO daily loss is 5% of the day start equity, breached on the second check
+	server.SetEquity("123", 99000, 94900)
+	server.AddPosition("123", broker.Position{ID: "1001", Instrument: "EURUSD", Units: 1, UnrealizedPL: -5100})
+	server.AddOrder("123", broker.Order{ID: "2001", Instrument: "EURUSD", Type: "BUY_LIMIT", Units: 1})
+
+	adapter, err := broker.NewAdapter(server.Client(), broker.MT5FTMO, config.BrokersConfig{
+		Single: map[string]config.SingleConnectionConfig{config.BrokerMT5: {ApiKey: "test-key", BaseUrl: server.URL}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create adapter: %v", err)
+	}
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	notifier := &fakeNotifier{}
+
+	et := NewEquityTracker(
+		repo,
+		profiles.Presets(),
+		map[string]string{broker.MT5FTMO: profiles.FTMO},
+		notifier,
+		events.NewHub(),
+		testConnections(adapter),
+		TrackerConfig{CheckInterval: time.Second, FlattenOnBreach: true},
+	)
+	et.timeProvider = &fixedTimeProvider{now: time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(server.Positions("123")) != 1 {
+		t.Fatalf("expected no flatten before breach, got %+v", server.Positions("123"))
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p, o := server.Positions("123"), server.Orders("123"); len(p) != 0 || len(o) != 0 {
+		t.Errorf("expected account to be flattened after breach, got positions %+v orders %+v", p, o)
+	}
+	if notifier.count(notifications.SeverityCritical) != 2 {
+		t.Errorf("expected breach and flatten notifications, got %+v", notifier.sent)
+	}
+}
+
+func TestCheckAndUpdateEquityWarnsOncePerLevel(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	adapter := &fakeAdapter{}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+
+	// FTMO daily loss allows 5000 from the day start equity of 100000
+	for _, equity := range []float64{99000, 97400, 97000, 98000, 96200, 95100, 95300} {
+		adapter.equity = equity
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var levels []string
+	for _, n := range notifier.sent {
+		if strings.HasPrefix(n.message, "RISK WARNING") {
+			levels = append(levels, strings.Fields(n.message)[2])
+		}
+	}
+	if strings.Join(levels, ",") != "50%,75%,90%" {
+		t.Errorf("expected a single warning per level, got %v: %+v", levels, notifier.sent)
+	}
+	if notifier.count(notifications.SeverityCritical) != 0 {
+		t.Errorf("expected no breach, got %+v", notifier.sent)
+	}
+	if len(repo.events) != 3 || repo.events[0].Level != db.RiskLevelWarning || repo.events[0].Action != db.ActionNotified || repo.events[2].WarningLevel != 90 {
+		t.Errorf("expected warnings to be recorded as risk events, got %+v", repo.events)
+	}
+}
+
+func TestCheckAndUpdateEquityDoesNotRepeatWarningsAfterRestart(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	// 2600 of the 5000 daily loss allowance is used, crossing the 50% warning level
+	adapter := &fakeAdapter{equity: 97400}
+	now := time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)
+
+	first := &fakeNotifier{}
+	if err := newTestTracker(repo, adapter, first, now).checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.count(notifications.SeverityWarning) != 1 {
+		t.Fatalf("expected a warning before the restart, got %+v", first.sent)
+	}
+
+	// After a restart the warning already recorded today is not notified again, but a higher level still is
+	restarted := &fakeNotifier{}
+	et := newTestTracker(repo, adapter, restarted, now.Add(time.Minute))
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restarted.count(notifications.SeverityWarning) != 0 {
+		t.Errorf("expected no repeated warning after the restart, got %+v", restarted.sent)
+	}
+
+	adapter.equity = 96200
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restarted.count(notifications.SeverityWarning) != 1 {
+		t.Errorf("expected the 75%% warning after the restart, got %+v", restarted.sent)
+	}
+}
+
+func TestCheckAndUpdateEquityDoesNotRepeatBreachesAfterRestart(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	adapter := &fakeAdapter{equity: 94000}
+	now := time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)
+
+	first := &fakeNotifier{}
+	if err := newTestTracker(repo, adapter, first, now).checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.countPrefix("RISK BREACH") != 1 || adapter.flattened != 1 {
+		t.Fatalf("expected a breach and flatten before the restart, got %+v, flattened %d times", first.sent, adapter.flattened)
+	}
+
+	// After a restart the breach already recorded today is not notified, flattened or recorded again
+	restarted := &fakeNotifier{}
+	if err := newTestTracker(repo, adapter, restarted, now.Add(time.Minute)).checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restarted.countPrefix("RISK BREACH") != 0 {
+		t.Errorf("expected no repeated breach after the restart, got %+v", restarted.sent)
+	}
+	if adapter.flattened != 1 {
+		t.Errorf("expected no repeated flatten after the restart, flattened %d times", adapter.flattened)
+	}
+	breaches := 0
+	for _, e := range repo.events {
+		if e.Level == db.RiskLevelBreach {
+			breaches++
+		}
+	}
+	if breaches != 1 {
+		t.Errorf("expected 1 recorded breach, got %d", breaches)
+	}
+}
+
+func TestCheckAndUpdateEquityUpdatesStatus(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 104000},
+	}
+	adapter := &fakeAdapter{equity: 102000}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, prague))
+
+	if _, exists := et.AccountStatus("123"); exists {
+		t.Fatal("expected no status before the account is checked")
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status, exists := et.AccountStatus("123")
+	if !exists {
+		t.Fatal("expected status after the account is checked")
+	}
+
+	if pnl, ok := status.DailyPnL(); !ok || pnl != -2000 {
+		t.Errorf("DailyPnL() = %.2f, %v, want -2000", pnl, ok)
+	}
+	// FTMO daily loss limit is 5% below the day start equity of 104000
+	if h, ok := status.Headroom(rules.DailyLoss); !ok || h != 102000-98800 {
+		t.Errorf("daily loss headroom = %.2f, %v, want 3200", h, ok)
+	}
+	// FTMO max loss limit is 10% below the initial balance
+	if h, ok := status.Headroom(rules.MaxLoss, rules.TrailingDrawdown); !ok || h != 12000 {
+		t.Errorf("max drawdown headroom = %.2f, %v, want 12000", h, ok)
+	}
+	// FTMO profit target is 10% of the initial balance
+	if p, ok := status.ProfitTargetProgress(); !ok || math.Abs(p-20) > 1e-9 {
+		t.Errorf("ProfitTargetProgress() = %.2f, %v, want 20", p, ok)
+	}
+	if status.MinTradingDays != 4 {
+		t.Errorf("MinTradingDays = %d, want the FTMO minimum of 4", status.MinTradingDays)
+	}
+	if !status.NextReset.Equal(time.Date(2024, 1, 3, 0, 1, 0, 0, prague)) {
+		t.Errorf("NextReset = %v, want 2024-01-03 00:01 Prague", status.NextReset)
+	}
+
+	if g := testutil.ToFloat64(accountEquity.WithLabelValues("123", "ftmo")); g != 102000 {
+		t.Errorf("equity gauge = %.2f, want 102000", g)
+	}
+	if g := testutil.ToFloat64(accountLossUsed.WithLabelValues("123", "ftmo", rules.DailyLoss)); math.Abs(g-2000.0/5200*100) > 1e-9 {
+		t.Errorf("daily loss used gauge = %.2f, want %.2f", g, 2000.0/5200*100)
+	}
+}
+
+func TestCheckAndUpdateEquityPrunesRemovedAccounts(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "prune", BrokerType: broker.MT5FTMO, AccountID: "456", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	et := newTestTracker(repo, &fakeAdapter{equity: 99000}, &fakeNotifier{}, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	series := testutil.CollectAndCount(accountEquity)
+
+	// Renaming the broker replaces the account's series rather than adding to them
+	repo.accounts[0].BrokerName = "renamed"
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := testutil.CollectAndCount(accountEquity); n != series {
+		t.Errorf("expected %d equity series after the broker is renamed, got %d", series, n)
+	}
+	if g := testutil.ToFloat64(accountHalted.WithLabelValues("456", "renamed")); g != 0 {
+		t.Errorf("halted gauge = %v, want 0", g)
+	}
+
+	repo.accounts = nil
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, exists := et.AccountStatus("456"); exists {
+		t.Error("expected the status of the removed account to be deleted")
+	}
+	for name, vec := range map[string]*prometheus.GaugeVec{"equity": accountEquity, "daily pnl": accountDailyPnL, "loss used": accountLossUsed, "halted": accountHalted} {
+		if n := vec.DeletePartialMatch(prometheus.Labels{"account_id": "456"}); n != 0 {
+			t.Errorf("expected no %s series of the removed account, got %d", name, n)
+		}
+	}
+}
+
+func TestCheckAndUpdateEquityPublishesEvents(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	adapter := &fakeAdapter{equity: 97000}
+	et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+
+	stream, unsubscribe := et.hub.Subscribe(10)
+	defer unsubscribe()
+
+	for _, equity := range []float64{97000, 94000} {
+		adapter.equity = equity
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var types []string
+	for len(stream) > 0 {
+		e := <-stream
+		if e.AccountID != "123" {
+			t.Errorf("unexpected account in event %+v", e)
+		}
+		types = append(types, e.Type)
+	}
+	// FTMO daily loss allows 5000, so 97000 crosses its 50% warning level and 94000 breaches it,
+	// while crossing the 50% warning level of the 10000 max loss
+	want := []string{events.TypeEquity, events.TypeWarning, events.TypeEquity, events.TypeWarning, events.TypeBreach}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("published %v, want %v", types, want)
+	}
+}
+
+func TestCheckAndUpdateEquityUsesConfiguredRules(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo-1", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+			{BrokerAccount: broker.BrokerAccount{ID: 2, BrokerName: "ftmo-2", BrokerType: broker.MT5FTMO, AccountID: "456", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000, 2: 100000},
+		// Rules attached in the database take precedence over configured rules
+		rules: map[int64][]rules.Config{1: {{Type: rules.MaxLoss, LimitPercent: 6}}},
+	}
+	adapter := &fakeAdapter{equity: 99000}
+
+	et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 14, 0, 0, 0, prague))
+	et.config.AccountRules = map[string][]rules.Config{
+		"123": {{Type: rules.MaxLoss, LimitPercent: 7}},
+		"456": {{Type: rules.MaxLoss, LimitPercent: 8}},
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for accountId, want := range map[string]float64{"123": 6, "456": 8} {
+		status, _ := et.AccountStatus(accountId)
+		var threshold float64
+		for _, res := range status.Rules {
+			if res.Rule == rules.MaxLoss {
+				threshold = res.Threshold
+			}
+		}
+		if threshold != want {
+			t.Errorf("account %s max loss threshold = %.2f, want %.2f", accountId, threshold, want)
+		}
+	}
+}
+
+func TestCheckAndUpdateEquityRoutesAccountsToConnections(t *testing.T) {
+	logger.InitLogger()
+
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo-demo", BrokerType: broker.MT5FTMO, BrokerEnv: "DEMO", AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+			{BrokerAccount: broker.BrokerAccount{ID: 2, BrokerName: "ftmo-live", BrokerType: broker.MT5FTMO, BrokerEnv: "LIVE", AccountID: "456", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000, 2: 100000},
+	}
+	demo, live := &fakeAdapter{equity: 101000}, &fakeAdapter{equity: 99000}
+	notifier := &fakeNotifier{}
+
+	et := newTestTracker(repo, demo, notifier, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC))
+	et.connections, _ = broker.NewConnections(
+		broker.Connection{Name: "demo-bridge", Type: broker.MT5FTMO, Env: "DEMO", Adapter: demo},
+		broker.Connection{Name: "live-bridge", Type: broker.MT5FTMO, Env: "LIVE", Adapter: live},
+	)
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for accountId, want := range map[string]float64{"123": 101000, "456": 99000} {
+		if status, _ := et.AccountStatus(accountId); status.Equity != want {
+			t.Errorf("account %s equity = %.2f, want %.2f from its connection", accountId, status.Equity, want)
+		}
+	}
+}
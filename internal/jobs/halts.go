@@ -0,0 +1,213 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// ErrAccountNotFound is returned when an account is not an active broker account
+var ErrAccountNotFound = errors.New("account not found")
+
+// AccountHalt returns the active trading halt of the account, false if it is not halted.
+// The halt is read from the database, so it is known even if the account has not been checked since the service started
+func (et *EquityTracker) AccountHalt(ctx context.Context, accountId string) (db.AccountHalt, bool, error) {
+	account, err := et.findAccount(ctx, accountId)
+	if err != nil {
+		return db.AccountHalt{}, false, err
+	}
+
+	halts, err := et.brokerRepo.GetAccountHalts(ctx, et.timeProvider.Now())
+	if err != nil {
+		return db.AccountHalt{}, false, fmt.Errorf("error getting account halts: %v", err)
+	}
+	halt, halted := halts[account.ID]
+	return halt, halted, nil
+}
+
+// Halt halts trading on the account, replacing any existing halt. HaltUntilReset halts expire at the start of the next trading day,
+// HaltUntilReleased halts are held until the account is resumed. The account does not need to have been checked,
+// so accounts can be halted while their broker is unreachable
+func (et *EquityTracker) Halt(ctx context.Context, accountId, mode, reason string) (db.AccountHalt, error) {
+	account, err := et.findAccount(ctx, accountId)
+	if err != nil {
+		return db.AccountHalt{}, err
+	}
+
+	halt := db.AccountHalt{
+		BrokerAccountID: account.ID,
+		Mode:            mode,
+		Source:          db.HaltSourceManual,
+		Reason:          reason,
+		CreatedAt:       et.timeProvider.Now().UTC(),
+	}
+	switch mode {
+	case db.HaltUntilReset:
+		nextReset, err := et.nextReset(ctx, account)
+		if err != nil {
+			return db.AccountHalt{}, err
+		}
+		halt.Until = nextReset.UTC()
+	case db.HaltUntilReleased:
+	default:
+		return db.AccountHalt{}, fmt.Errorf("unsupported halt mode: %s", mode)
+	}
+
+	if err := et.setHalt(ctx, halt); err != nil {
+		return db.AccountHalt{}, err
+	}
+
+	logger.Warnf("Trading halted %s for broker %s: %s", mode, account.BrokerName, reason)
+	et.notifier.Notify(notifications.SeverityWarning, fmt.Sprintf("TRADING HALTED (%s) for broker %s: %s", mode, account.BrokerName, reason))
+	return halt, nil
+}
+
+// Resume releases any trading halt of the account
+func (et *EquityTracker) Resume(ctx context.Context, accountId string) error {
+	account, err := et.findAccount(ctx, accountId)
+	if err != nil {
+		return err
+	}
+
+	if err := et.brokerRepo.DeleteAccountHalt(ctx, account.ID); err != nil {
+		return fmt.Errorf("error deleting account halt: %v", err)
+	}
+
+	et.mu.Lock()
+	delete(et.halts, account.ID)
+	et.mu.Unlock()
+
+	logger.Infof("Trading resumed for broker %s", account.BrokerName)
+	et.notifier.Notify(notifications.SeverityInfo, fmt.Sprintf("TRADING RESUMED for broker %s", account.BrokerName))
+	return nil
+}
+
+// findAccount returns the active broker account with the broker's account ID, ErrAccountNotFound if there is none
+func (et *EquityTracker) findAccount(ctx context.Context, accountId string) (broker.BrokerWithLastEquity, error) {
+	accounts, err := et.brokerRepo.GetActiveBrokers(ctx)
+	if err != nil {
+		return broker.BrokerWithLastEquity{}, fmt.Errorf("error getting active brokers: %v", err)
+	}
+	for _, account := range accounts {
+		if account.AccountID == accountId {
+			return account, nil
+		}
+	}
+	return broker.BrokerWithLastEquity{}, ErrAccountNotFound
+}
+
+// nextReset returns the start of the next trading day of the account, from its profile
+func (et *EquityTracker) nextReset(ctx context.Context, account broker.BrokerWithLastEquity) (time.Time, error) {
+	assignments, err := et.brokerRepo.GetAccountProfiles(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting account profiles: %v", err)
+	}
+
+	// The profiles are replaced by reloads, so are read under the lock outside of ticks
+	et.mu.Lock()
+	profile, err := resolveProfile(et.profiles, et.defaultProfiles, account, assignments)
+	et.mu.Unlock()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	location, err := time.LoadLocation(profile.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error loading timezone %s: %v", profile.Timezone, err)
+	}
+	now := et.timeProvider.Now().In(location)
+	return tradingDayStart(now, profile.DailyUpdateHour, profile.DailyUpdateMinute).AddDate(0, 0, 1), nil
+}
+
+// activeHalt returns the halt of the broker account if it has not expired
+func (et *EquityTracker) activeHalt(brokerID int64, now time.Time) (db.AccountHalt, bool) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	halt, exists := et.halts[brokerID]
+	if !exists || !halt.Active(now) {
+		return db.AccountHalt{}, false
+	}
+	return halt, true
+}
+
+// setHalt persists the halt and applies it immediately, rather than from the next tick
+func (et *EquityTracker) setHalt(ctx context.Context, halt db.AccountHalt) error {
+	if err := et.brokerRepo.SetAccountHalt(ctx, halt); err != nil {
+		return fmt.Errorf("error setting account halt: %v", err)
+	}
+
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	et.halts[halt.BrokerAccountID] = halt
+	return nil
+}
+
+// haltOnBreach halts trading on the account until the next trading day after a rule is breached.
+// An existing halt is kept, so a breach never shortens a manual halt
+func (et *EquityTracker) haltOnBreach(ctx context.Context, account broker.BrokerWithLastEquity, breach rules.Breach, nextReset time.Time) {
+	if _, halted := et.activeHalt(account.ID, breach.Time); halted {
+		return
+	}
+
+	halt := db.AccountHalt{
+		BrokerAccountID: account.ID,
+		Mode:            db.HaltUntilReset,
+		Source:          db.HaltSourceBreach,
+		Reason:          breach.String(),
+		Until:           nextReset.UTC(),
+		CreatedAt:       breach.Time.UTC(),
+	}
+	if err := et.setHalt(ctx, halt); err != nil {
+		msg := fmt.Sprintf("Error halting broker %s after risk breach", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "halt"), msg, err)
+		return
+	}
+	et.alerts.Resolve(accountAlertKey(account.ID, "halt"), fmt.Sprintf("Halted broker %s", account.BrokerName))
+	logger.Warnf("Trading halted until %v for broker %s", nextReset, account.BrokerName)
+}
+
+// enforceHalt flattens any positions or orders opened on a halted account. Halts set on breach are only enforced if FlattenOnBreach is enabled,
+// manual halts are always enforced
+func (et *EquityTracker) enforceHalt(ctx context.Context, account broker.BrokerWithLastEquity, adapter broker.BrokerAdapter, now time.Time) {
+	halt, halted := et.activeHalt(account.ID, now)
+	if !halted || (halt.Source == db.HaltSourceBreach && !et.config.FlattenOnBreach) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, flattenTimeout)
+	defer cancel()
+
+	positions, err := adapter.GetOpenPositions(ctx, account.AccountID)
+	if err != nil {
+		logger.Errorf("Error getting positions of halted broker %s: %v", account.BrokerName, err)
+		return
+	}
+	orders, err := adapter.GetPendingOrders(ctx, account.AccountID)
+	if err != nil {
+		logger.Errorf("Error getting orders of halted broker %s: %v", account.BrokerName, err)
+		return
+	}
+	if len(positions) == 0 && len(orders) == 0 {
+		return
+	}
+
+	logger.Warnf("Found %d positions and %d orders on halted broker %s, flattening", len(positions), len(orders), account.BrokerName)
+
+	if err := broker.Flatten(ctx, adapter, account.AccountID); err != nil {
+		msg := fmt.Sprintf("Error flattening halted broker %s, retrying next check", account.BrokerName)
+		logger.Errorf("%s: %v", msg, err)
+		et.alerts.Alert(accountAlertKey(account.ID, "flatten"), msg, err)
+		return
+	}
+
+	et.alerts.Resolve(accountAlertKey(account.ID, "flatten"), fmt.Sprintf("Flattened broker %s", account.BrokerName))
+	et.notifier.Notify(notifications.SeverityCritical, fmt.Sprintf("Closed %d positions and cancelled %d orders opened on halted broker %s (%s)", len(positions), len(orders), account.BrokerName, halt.Reason))
+}
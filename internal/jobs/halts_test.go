@@ -0,0 +1,190 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/db"
+	"github.com/jwtly10/at4j-risk-manager/internal/notifications"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+func newHaltTestRepo() *fakeRepo {
+	return &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+}
+
+func TestBreachHaltsUntilReset(t *testing.T) {
+	logger.InitLogger()
+
+	repo := newHaltTestRepo()
+	adapter := &fakeAdapter{equity: 94000}
+	et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	halt, halted, err := et.AccountHalt(context.Background(), "123")
+	if err != nil || !halted {
+		t.Fatalf("expected account to be halted after breach, got %v, %v", halted, err)
+	}
+	if halt.Mode != db.HaltUntilReset || halt.Source != db.HaltSourceBreach {
+		t.Errorf("unexpected halt %+v", halt)
+	}
+	// FTMO resets at 00:01 Prague time
+	if want := time.Date(2024, 1, 2, 23, 1, 0, 0, time.UTC); !halt.Until.Equal(want) {
+		t.Errorf("Until = %v, want %v", halt.Until, want)
+	}
+	if _, persisted := repo.halts[1]; !persisted {
+		t.Error("expected halt to be persisted")
+	}
+
+	// A position opened after the breach is flattened while halted
+	adapter.equity = 96000
+	adapter.positions = []broker.Position{{ID: "1", Instrument: "EURUSD", Units: 1000}}
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if adapter.flattened != 2 || len(adapter.positions) != 0 {
+		t.Errorf("expected new position to be flattened while halted, flattened %d times", adapter.flattened)
+	}
+
+	// The halt expires at the next reset
+	et.timeProvider = &fixedTimeProvider{now: time.Date(2024, 1, 2, 23, 2, 0, 0, time.UTC)}
+	adapter.positions = []broker.Position{{ID: "2", Instrument: "EURUSD", Units: 1000}}
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, halted, _ := et.AccountHalt(context.Background(), "123"); halted {
+		t.Error("expected halt to expire at the next reset")
+	}
+	if len(adapter.positions) != 1 {
+		t.Error("expected no flatten after the halt expired")
+	}
+}
+
+func TestManualHaltAndResume(t *testing.T) {
+	logger.InitLogger()
+
+	repo := newHaltTestRepo()
+	adapter := &fakeAdapter{equity: 99000}
+	notifier := &fakeNotifier{}
+	et := newTestTracker(repo, adapter, notifier, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+
+	if _, err := et.Halt(context.Background(), "456", db.HaltUntilReleased, "news"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound for an unknown account, got %v", err)
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := et.Halt(context.Background(), "123", "FOREVER", "news"); err == nil {
+		t.Error("expected error for unsupported mode")
+	}
+
+	halt, err := et.Halt(context.Background(), "123", db.HaltUntilReleased, "news")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if halt.Source != db.HaltSourceManual || !halt.Until.IsZero() {
+		t.Errorf("unexpected halt %+v", halt)
+	}
+	if notifier.count(notifications.SeverityWarning) != 1 {
+		t.Errorf("expected halt notification, got %+v", notifier.sent)
+	}
+
+	decision, err := et.CheckOrder(context.Background(), OrderCheck{AccountID: "123", Units: 1, StopLossDistance: 0.001, ConversionRate: 1})
+	if err != nil || decision.Allowed {
+		t.Errorf("expected orders to be denied while halted, got %+v, %v", decision, err)
+	}
+
+	// A breach does not replace the manual halt
+	adapter.equity = 94000
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if halt, _, _ := et.AccountHalt(context.Background(), "123"); halt.Mode != db.HaltUntilReleased {
+		t.Errorf("expected manual halt to be kept on breach, got %+v", halt)
+	}
+
+	if err := et.Resume(context.Background(), "123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, halted, _ := et.AccountHalt(context.Background(), "123"); halted {
+		t.Error("expected account to be resumed")
+	}
+	if len(repo.halts) != 0 {
+		t.Errorf("expected halt to be deleted, got %+v", repo.halts)
+	}
+}
+
+func TestHaltBeforeFirstCheck(t *testing.T) {
+	logger.InitLogger()
+
+	repo := newHaltTestRepo()
+	adapter := &fakeAdapter{equity: 99000}
+	et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+
+	halt, err := et.Halt(context.Background(), "123", db.HaltUntilReset, "broker outage")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if halt.BrokerAccountID != 1 {
+		t.Errorf("BrokerAccountID = %d, want 1", halt.BrokerAccountID)
+	}
+	// FTMO resets at 00:01 Prague time
+	if want := time.Date(2024, 1, 2, 23, 1, 0, 0, time.UTC); !halt.Until.Equal(want) {
+		t.Errorf("Until = %v, want %v", halt.Until, want)
+	}
+	if _, halted, _ := et.AccountHalt(context.Background(), "123"); !halted {
+		t.Error("expected account to be halted before it is checked")
+	}
+
+	// The halt is enforced from the first check
+	adapter.positions = []broker.Position{{ID: "1", Instrument: "EURUSD", Units: 1000}}
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if adapter.flattened != 1 || len(adapter.positions) != 0 {
+		t.Errorf("expected positions to be flattened on the first check, flattened %d times", adapter.flattened)
+	}
+
+	if err := et.Resume(context.Background(), "123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, halted, _ := et.AccountHalt(context.Background(), "123"); halted {
+		t.Error("expected account to be resumed")
+	}
+}
+
+func TestBreachHaltNotEnforcedWithoutFlatten(t *testing.T) {
+	logger.InitLogger()
+
+	repo := newHaltTestRepo()
+	adapter := &fakeAdapter{equity: 94000}
+	et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+	et.config.FlattenOnBreach = false
+
+	for range 2 {
+		adapter.positions = []broker.Position{{ID: "1", Instrument: "EURUSD", Units: 1000}}
+		if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, halted, _ := et.AccountHalt(context.Background(), "123"); !halted {
+		t.Error("expected account to be halted after breach")
+	}
+	if adapter.flattened != 0 {
+		t.Errorf("expected no flatten with FlattenOnBreach disabled, flattened %d times", adapter.flattened)
+	}
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+)
+
+// staleTicks is the number of check intervals without a completed tick after which the tracker is unhealthy
+const staleTicks = 3
+
+// minStaleAfter is the lower bound of how long the tracker can go without completing a tick, so short check intervals
+// still allow for a slow tick
+const minStaleAfter = time.Minute
+
+// TrackerHealth is the health of the equity tracker
+type TrackerHealth struct {
+	Stats TickStats
+	// Age is the time since the last completed tick, or since the tracker started if no tick has completed yet.
+	// Failed ticks count, so a failing dependency such as the database does not make the tracker look wedged
+	Age time.Duration
+	// StaleAfter is the max age of a healthy tracker
+	StaleAfter time.Duration
+	// Healthy is false if the tracker has not been started or has not completed a tick within StaleAfter
+	Healthy bool
+	// Circuits is the circuit breaker state of each broker connection whose adapter has one, by connection name
+	Circuits map[string]broker.CircuitState
+}
+
+// Health returns the health of the tracker, so a wedged tracker can be detected and restarted
+func (et *EquityTracker) Health() TrackerHealth {
+	return et.health(time.Now())
+}
+
+func (et *EquityTracker) health(now time.Time) TrackerHealth {
+	stats := et.Stats()
+
+	h := TrackerHealth{
+		Stats:      stats,
+		StaleAfter: max(staleTicks*et.config.CheckInterval, minStaleAfter),
+		Circuits:   make(map[string]broker.CircuitState),
+	}
+
+	if !stats.StartedAt.IsZero() {
+		since := stats.StartedAt
+		if stats.LastTickAt.After(since) {
+			since = stats.LastTickAt
+		}
+		h.Age = now.Sub(since)
+		h.Healthy = h.Age <= h.StaleAfter
+	}
+
+	for _, conn := range et.connections.All() {
+		if reporter, ok := conn.Adapter.(broker.CircuitReporter); ok {
+			h.Circuits[conn.Name] = reporter.CircuitState()
+		}
+	}
+	return h
+}
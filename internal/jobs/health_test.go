@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+func TestTrackerHealth(t *testing.T) {
+	logger.InitLogger()
+
+	start := time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)
+	et := newTestTracker(&fakeRepo{}, &fakeAdapter{circuit: broker.CircuitOpen}, &fakeNotifier{}, start)
+
+	if h := et.health(start); h.Healthy {
+		t.Error("expected tracker not to be healthy before it is started")
+	}
+
+	et.stats.StartedAt = start
+	if h := et.health(start.Add(30 * time.Second)); !h.Healthy {
+		t.Errorf("expected tracker to be healthy while waiting for its first tick, got %+v", h)
+	}
+	if h := et.health(start.Add(2 * time.Minute)); h.Healthy {
+		t.Errorf("expected tracker without a completed tick to be unhealthy, got %+v", h)
+	}
+
+	et.recordTick(start.Add(90*time.Second), time.Second, nil)
+	et.recordTick(start.Add(100*time.Second), time.Second, errors.New("db unavailable"))
+
+	h := et.health(start.Add(2 * time.Minute))
+	if !h.Healthy || h.Age != 20*time.Second {
+		t.Errorf("expected age from the last completed tick, got %+v", h)
+	}
+
+	// A database outage fails every tick, but the tracker is still running
+	et.recordTick(start.Add(5*time.Minute), time.Second, errors.New("db unavailable"))
+	if h := et.health(start.Add(5*time.Minute + 30*time.Second)); !h.Healthy {
+		t.Errorf("expected tracker with failing ticks to be healthy, got %+v", h)
+	}
+	if h.Circuits[broker.MT5FTMO] != broker.CircuitOpen {
+		t.Errorf("expected broker circuit to be reported, got %+v", h.Circuits)
+	}
+}
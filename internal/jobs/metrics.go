@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	accountEquity = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "risk_manager_account_equity",
+		Help: "Live equity of the account as of its last equity check.",
+	}, []string{"account_id", "broker_name"})
+	accountDailyPnL = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "risk_manager_account_daily_pnl",
+		Help: "Profit or loss of the account since the start of the trading day.",
+	}, []string{"account_id", "broker_name"})
+	accountLossUsed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "risk_manager_account_loss_used_percent",
+		Help: "Percentage of the allowed loss of each rule used by the account. 100 or more is a breach.",
+	}, []string{"account_id", "broker_name", "rule"})
+	accountHalted = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "risk_manager_account_halted",
+		Help: "1 if trading is halted on the account, 0 otherwise.",
+	}, []string{"account_id", "broker_name"})
+	checkDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "risk_manager_equity_check_duration_seconds",
+		Help:    "Duration of each run of the equity check job across all accounts.",
+		Buckets: prometheus.DefBuckets,
+	})
+	checkOverruns = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "risk_manager_equity_check_overruns_total",
+		Help: "Runs of the equity check job that took longer than the check interval.",
+	})
+)
+
+// deleteAccountMetrics removes the series of the account, so accounts that are no longer tracked are not reported
+func deleteAccountMetrics(accountId string) {
+	labels := prometheus.Labels{"account_id": accountId}
+	accountEquity.DeletePartialMatch(labels)
+	accountDailyPnL.DeletePartialMatch(labels)
+	accountLossUsed.DeletePartialMatch(labels)
+	accountHalted.DeletePartialMatch(labels)
+}
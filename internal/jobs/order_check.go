@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// ErrAccountNotTracked is returned when an account has not been checked since the service started
+var ErrAccountNotTracked = errors.New("account not tracked")
+
+// OrderCheck is a proposed order to check against the remaining risk headroom of an account
+type OrderCheck struct {
+	AccountID  string
+	Instrument string
+	// Units is the size of the order, negative for sells
+	Units float64
+	// StopLossDistance is the price distance between the entry and the stop loss
+	StopLossDistance float64
+	// ConversionRate converts the quote currency of the instrument to the account currency. 1 if they are the same
+	ConversionRate float64
+}
+
+// Risk returns the loss in the account currency if the order is stopped out
+func (o OrderCheck) Risk() float64 {
+	return math.Abs(o.Units) * o.StopLossDistance * o.ConversionRate
+}
+
+// OrderDecision is the outcome of a pre-trade risk check
+type OrderDecision struct {
+	Allowed bool
+	Reason  string
+	// Equity is the live equity the order was checked against, 0 if it could not be fetched
+	Equity float64
+	// Risk is the loss in the account currency if the order is stopped out
+	Risk float64
+	// Rule is the rule with the least headroom, empty if the account has no rules
+	Rule string
+	// Headroom is how far equity can drop before Rule is breached
+	Headroom float64
+}
+
+// CheckOrder decides whether an order may be placed, by checking that its risk fits within the remaining headroom
+// of every rule of the account at its live equity. Orders are denied whenever the risk cannot be determined
+func (et *EquityTracker) CheckOrder(ctx context.Context, order OrderCheck) (OrderDecision, error) {
+	status, exists := et.AccountStatus(order.AccountID)
+	if !exists {
+		return OrderDecision{}, ErrAccountNotTracked
+	}
+
+	decision := OrderDecision{Risk: order.Risk()}
+
+	conn, err := et.connections.Resolve(broker.BrokerAccount{AccountID: status.AccountID, BrokerType: status.brokerType, BrokerEnv: status.brokerEnv})
+	if err != nil {
+		decision.Reason = fmt.Sprintf("No broker connection for broker type %s", status.brokerType)
+		return decision, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, et.accountTimeout())
+	defer cancel()
+
+	equity, err := conn.Adapter.GetEquity(ctx, order.AccountID)
+	if err != nil {
+		logger.Warnf("Error getting equity for order check of broker %s: %v", status.BrokerName, err)
+		decision.Reason = fmt.Sprintf("Unable to get live equity from broker %s", status.BrokerName)
+		return decision, nil
+	}
+	decision.Equity = equity
+
+	now := et.timeProvider.Now()
+	if !now.Before(status.NextReset) {
+		decision.Reason = "Trading day has reset since the last equity check, awaiting the day start equity"
+		return decision, nil
+	}
+
+	if halt, halted := et.activeHalt(status.brokerID, now); halted {
+		decision.Reason = fmt.Sprintf("Trading halted (%s): %s", halt.Mode, halt.Reason)
+		return decision, nil
+	}
+
+	if len(status.breached) > 0 {
+		decision.Reason = fmt.Sprintf("Rule '%s' has been breached today", status.breached[0])
+		return decision, nil
+	}
+
+	snapshot := status.snapshot
+	snapshot.Equity = equity
+	snapshot.IntradayHighWaterMark = max(snapshot.IntradayHighWaterMark, equity)
+	snapshot.Time = now
+
+	found := false
+	for _, rule := range status.rules {
+		res := rule.Evaluate(snapshot)
+		if res.Limit <= 0 {
+			continue
+		}
+		headroom := res.Equity - res.Limit
+		if !found || headroom < decision.Headroom {
+			decision.Rule = res.Rule
+			decision.Headroom = headroom
+		}
+		found = true
+	}
+
+	// A rule is breached once equity reaches its limit, so the risk must be strictly less than the headroom
+	if found && decision.Risk >= decision.Headroom {
+		decision.Reason = fmt.Sprintf("Order risk %.2f exceeds the remaining headroom %.2f of rule '%s'", decision.Risk, max(decision.Headroom, 0), decision.Rule)
+		return decision, nil
+	}
+
+	decision.Allowed = true
+	decision.Reason = "Order within risk limits"
+	return decision, nil
+}
@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+func TestCheckOrder(t *testing.T) {
+	logger.InitLogger()
+
+	// FTMO daily loss limit is 5% below the day start equity of 100000, at 95000
+	tests := []struct {
+		name        string
+		checkEquity float64
+		liveEquity  float64
+		liveErr     error
+		now         time.Time
+		order       OrderCheck
+		wantAllowed bool
+		wantRule    string
+	}{
+		{
+			name:        "Risk within headroom",
+			checkEquity: 99000,
+			liveEquity:  99000,
+			order:       OrderCheck{Units: 100000, StopLossDistance: 0.002, ConversionRate: 1},
+			wantAllowed: true,
+			wantRule:    "daily_loss",
+		},
+		{
+			name:        "Risk exceeds headroom at live equity",
+			checkEquity: 99000,
+			liveEquity:  95100,
+			order:       OrderCheck{Units: -100000, StopLossDistance: 0.002, ConversionRate: 1},
+			wantRule:    "daily_loss",
+		},
+		{
+			name:        "Risk exactly reaches limit",
+			checkEquity: 99000,
+			liveEquity:  95200,
+			order:       OrderCheck{Units: 100000, StopLossDistance: 0.002, ConversionRate: 1},
+			wantRule:    "daily_loss",
+		},
+		{
+			name:        "Conversion rate applied to risk",
+			checkEquity: 99000,
+			liveEquity:  99000,
+			order:       OrderCheck{Units: 100000, StopLossDistance: 0.3, ConversionRate: 1.0 / 150},
+			wantAllowed: true,
+			wantRule:    "daily_loss",
+		},
+		{
+			name:        "Breached earlier today",
+			checkEquity: 94000,
+			liveEquity:  99000,
+			order:       OrderCheck{Units: 1, StopLossDistance: 0.001, ConversionRate: 1},
+		},
+		{
+			name:        "Broker unreachable",
+			checkEquity: 99000,
+			liveErr:     errors.New("connection refused"),
+			order:       OrderCheck{Units: 1, StopLossDistance: 0.001, ConversionRate: 1},
+		},
+		{
+			name:        "Trading day reset since last check",
+			checkEquity: 99000,
+			liveEquity:  99000,
+			now:         time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC),
+			order:       OrderCheck{Units: 1, StopLossDistance: 0.001, ConversionRate: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{
+				accounts: []broker.BrokerWithLastEquity{
+					{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+				},
+				dayStart: map[int64]float64{1: 100000},
+			}
+			adapter := &fakeAdapter{equity: tt.checkEquity}
+			et := newTestTracker(repo, adapter, &fakeNotifier{}, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+
+			if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			adapter.equity, adapter.equityErr = tt.liveEquity, tt.liveErr
+			if !tt.now.IsZero() {
+				et.timeProvider = &fixedTimeProvider{now: tt.now}
+			}
+
+			tt.order.AccountID = "123"
+			decision, err := et.CheckOrder(context.Background(), tt.order)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if decision.Allowed != tt.wantAllowed {
+				t.Errorf("Allowed = %v, want %v (reason: %s)", decision.Allowed, tt.wantAllowed, decision.Reason)
+			}
+			if decision.Reason == "" {
+				t.Error("expected a reason for the decision")
+			}
+			if tt.wantRule != "" && decision.Rule != tt.wantRule {
+				t.Errorf("Rule = %s, want %s", decision.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestCheckOrderUnknownAccount(t *testing.T) {
+	et := newTestTracker(&fakeRepo{}, &fakeAdapter{}, &fakeNotifier{}, time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC))
+
+	_, err := et.CheckOrder(context.Background(), OrderCheck{AccountID: "123", Units: 1, StopLossDistance: 1, ConversionRate: 1})
+	if !errors.Is(err, ErrAccountNotTracked) {
+		t.Errorf("expected ErrAccountNotTracked, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// pendingReload is reloaded configuration waiting to be applied at the start of the next tick
+type pendingReload struct {
+	catalogue       profiles.Catalogue
+	defaultProfiles map[string]string
+	config          TrackerConfig
+}
+
+// Reload replaces the profiles, default profiles and rule settings of the tracker. The check interval and max concurrency
+// can't be changed while the tracker runs and are kept.
+// The new configuration is applied as a whole at the start of the next tick, so a tick never mixes old and new settings
+func (et *EquityTracker) Reload(catalogue profiles.Catalogue, defaultProfiles map[string]string, config TrackerConfig) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	et.pending = &pendingReload{
+		catalogue:       catalogue,
+		defaultProfiles: defaultProfiles,
+		config:          config,
+	}
+}
+
+// applyReload applies any pending reload. Called before a tick's account checks start, as they read the settings without locking
+func (et *EquityTracker) applyReload() {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	if et.pending == nil {
+		return
+	}
+
+	et.profiles = et.pending.catalogue
+	et.defaultProfiles = et.pending.defaultProfiles
+	et.config.FlattenOnBreach = et.pending.config.FlattenOnBreach
+	et.config.Sampling = et.pending.config.Sampling
+	et.config.WarningLevels = et.pending.config.WarningLevels
+	et.config.AccountRules = et.pending.config.AccountRules
+	et.pending = nil
+
+	logger.Infof("Applied reloaded equity tracker configuration")
+}
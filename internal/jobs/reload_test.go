@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+func TestReloadAppliesOnNextTick(t *testing.T) {
+	logger.InitLogger()
+
+	prague, _ := time.LoadLocation("Europe/Prague")
+	repo := &fakeRepo{
+		accounts: []broker.BrokerWithLastEquity{
+			{BrokerAccount: broker.BrokerAccount{ID: 1, BrokerName: "ftmo", BrokerType: broker.MT5FTMO, AccountID: "123", InitialBalance: 100000}, LastEquityUpdate: &todaysSnapshot},
+		},
+		dayStart: map[int64]float64{1: 100000},
+	}
+	et := newTestTracker(repo, &fakeAdapter{equity: 99000}, &fakeNotifier{}, time.Date(2024, 1, 2, 14, 0, 0, 0, prague))
+
+	threshold := func(rule string) float64 {
+		status, _ := et.AccountStatus("123")
+		for _, res := range status.Rules {
+			if res.Rule == rule {
+				return res.Threshold
+			}
+		}
+		return 0
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if th := threshold(rules.DailyLoss); th != 5 {
+		t.Fatalf("daily loss threshold = %.2f, want the FTMO 5", th)
+	}
+
+	catalogue := profiles.Presets()
+	ftmo := catalogue[profiles.FTMO]
+	ftmo.DailyLossPercent = 3
+	catalogue[profiles.FTMO] = ftmo
+	et.Reload(catalogue, map[string]string{broker.MT5FTMO: profiles.FTMO}, TrackerConfig{
+		CheckInterval: time.Hour,
+		AccountRules:  map[string][]rules.Config{"123": {{Type: rules.MaxLoss, LimitPercent: 7}}},
+	})
+
+	if et.config.CheckInterval != time.Second {
+		t.Errorf("check interval = %v, expected it to be kept", et.config.CheckInterval)
+	}
+	if th := threshold(rules.DailyLoss); th != 5 {
+		t.Errorf("daily loss threshold = %.2f, expected the reload to wait for the next tick", th)
+	}
+
+	if err := et.checkAndUpdateEquity(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if th := threshold(rules.DailyLoss); th != 3 {
+		t.Errorf("daily loss threshold = %.2f, want the reloaded 3", th)
+	}
+	if th := threshold(rules.MaxLoss); th != 7 {
+		t.Errorf("max loss threshold = %.2f, want the reloaded 7", th)
+	}
+	if len(et.config.WarningLevels) != 0 {
+		t.Errorf("warning levels = %v, want the reloaded levels", et.config.WarningLevels)
+	}
+}
@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/broker"
+	"github.com/jwtly10/at4j-risk-manager/internal/profiles"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+)
+
+// AccountStatus is the risk state of an account as of its last successful equity check
+type AccountStatus struct {
+	AccountID  string
+	BrokerName string
+	Profile    string
+	Timezone   string
+	Equity     float64
+	// DayStartEquity is 0 if no equity has been recorded for the trading day yet
+	DayStartEquity float64
+	// DayStartApproximate is true if the day start equity was recorded late
+	DayStartApproximate bool
+	InitialBalance      float64
+	// ProfitTargetPercent is the profit over the initial balance required to pass. 0 if there is no target
+	ProfitTargetPercent float64
+	// MinTradingDays is the minimum number of days that must be traded to pass. 0 if there is no minimum
+	MinTradingDays int
+	// Rules are the results of evaluating each of the account's rules
+	Rules []rules.Result
+	// NextReset is the start of the next trading day, in the profile's timezone
+	NextReset time.Time
+	// UpdatedAt is when the equity was fetched
+	UpdatedAt time.Time
+
+	// brokerID is the internal ID of the broker account
+	brokerID int64
+	// brokerType, brokerEnv, snapshot and rules are kept to re-evaluate the account against live equity for order checks
+	brokerType string
+	brokerEnv  string
+	snapshot   rules.Snapshot
+	rules      []rules.Rule
+	// breached are the rules breached during the current trading day
+	breached []string
+}
+
+// DailyPnL returns the profit or loss since the start of the trading day, false if there is no day start equity
+func (s AccountStatus) DailyPnL() (float64, bool) {
+	if s.DayStartEquity <= 0 {
+		return 0, false
+	}
+	return s.Equity - s.DayStartEquity, true
+}
+
+// Headroom returns the smallest amount equity can drop before breaching any of the named rules,
+// false if none of the rules are enabled for the account
+func (s AccountStatus) Headroom(ruleNames ...string) (float64, bool) {
+	headroom, found := 0.0, false
+	for _, res := range s.Rules {
+		for _, name := range ruleNames {
+			if res.Rule != name || res.Limit <= 0 {
+				continue
+			}
+			h := res.Equity - res.Limit
+			if !found || h < headroom {
+				headroom = h
+			}
+			found = true
+		}
+	}
+	return headroom, found
+}
+
+// ProfitTarget returns the equity required to pass, false if the profile has no profit target
+func (s AccountStatus) ProfitTarget() (float64, bool) {
+	if s.ProfitTargetPercent <= 0 || s.InitialBalance <= 0 {
+		return 0, false
+	}
+	return s.InitialBalance * (1 + s.ProfitTargetPercent/100), true
+}
+
+// ProfitTargetProgress returns the profit made as a percentage of the profit target, negative while in loss
+func (s AccountStatus) ProfitTargetProgress() (float64, bool) {
+	target, ok := s.ProfitTarget()
+	if !ok {
+		return 0, false
+	}
+	return (s.Equity - s.InitialBalance) / (target - s.InitialBalance) * 100, true
+}
+
+// AccountStatus returns the risk status of the account as of its last successful equity check, false if it has not been checked
+func (et *EquityTracker) AccountStatus(accountId string) (AccountStatus, bool) {
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	s, exists := et.statuses[accountId]
+	return s, exists
+}
+
+// updateStatus stores the risk status of the account for the status API
+func (et *EquityTracker) updateStatus(state *accountState, account broker.BrokerWithLastEquity, profile profiles.Profile, snapshot rules.Snapshot, accountRules []rules.Rule, b baseline) {
+	results := make([]rules.Result, 0, len(accountRules))
+	var breached []string
+	for _, rule := range accountRules {
+		res := rule.Evaluate(snapshot)
+		results = append(results, res)
+		if last, exists := state.breaches[res.Rule]; res.Breached || (exists && last.Equal(b.dayStart)) {
+			breached = append(breached, res.Rule)
+		}
+	}
+
+	status := AccountStatus{
+		AccountID:           account.AccountID,
+		BrokerName:          account.BrokerName,
+		Profile:             profile.Name,
+		Timezone:            profile.Timezone,
+		Equity:              snapshot.Equity,
+		DayStartEquity:      snapshot.DayStartEquity,
+		DayStartApproximate: b.approximate,
+		InitialBalance:      snapshot.InitialBalance,
+		ProfitTargetPercent: profile.ProfitTargetPercent,
+		MinTradingDays:      profile.MinTradingDays,
+		Rules:               results,
+		NextReset:           b.dayStart.AddDate(0, 0, 1),
+		UpdatedAt:           snapshot.Time,
+		brokerID:            account.ID,
+		brokerType:          account.BrokerType,
+		brokerEnv:           account.BrokerEnv,
+		snapshot:            snapshot,
+		rules:               accountRules,
+		breached:            breached,
+	}
+
+	accountEquity.WithLabelValues(account.AccountID, account.BrokerName).Set(status.Equity)
+	if pnl, ok := status.DailyPnL(); ok {
+		accountDailyPnL.WithLabelValues(account.AccountID, account.BrokerName).Set(pnl)
+	}
+	for _, res := range results {
+		accountLossUsed.WithLabelValues(account.AccountID, account.BrokerName, res.Rule).Set(res.UsedPercent)
+	}
+
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	et.statuses[account.AccountID] = status
+}
+
+// pruneAccounts removes the status and metrics of accounts that are no longer active, or whose broker name has changed,
+// so they are not reported from their last check
+func (et *EquityTracker) pruneAccounts(accounts []broker.BrokerWithLastEquity) {
+	active := make(map[string]string, len(accounts))
+	for _, account := range accounts {
+		active[account.AccountID] = account.BrokerName
+	}
+
+	et.mu.Lock()
+	defer et.mu.Unlock()
+	for accountId, status := range et.statuses {
+		brokerName, exists := active[accountId]
+		if exists && brokerName == status.BrokerName {
+			continue
+		}
+		deleteAccountMetrics(accountId)
+		if !exists {
+			delete(et.statuses, accountId)
+		}
+	}
+}
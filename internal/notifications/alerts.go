@@ -0,0 +1,106 @@
+package notifications
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/utils"
+)
+
+// Default backoff applied between repeats of the same alert
+const (
+	DefaultInitialBackoff = time.Minute
+	DefaultMaxBackoff     = time.Hour
+)
+
+// alertState tracks an active alert for a key
+type alertState struct {
+	message   string
+	firstSeen time.Time
+	lastSent  time.Time
+	backoff   time.Duration
+	// suppressed is the number of repeats not sent since the last notification
+	suppressed int
+}
+
+// AlertManager groups alerts by key, so a persistent condition is notified once,
+// repeated with exponential backoff while it continues, and resolved with a single message when it clears
+type AlertManager struct {
+	notifier       Notifier
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeProvider   utils.TimeProvider
+
+	mu     sync.Mutex
+	alerts map[string]*alertState
+}
+
+func NewAlertManager(notifier Notifier, initialBackoff, maxBackoff time.Duration) *AlertManager {
+	return &AlertManager{
+		notifier:       notifier,
+		initialBackoff: initialBackoff,
+		maxBackoff:     maxBackoff,
+		timeProvider:   utils.RealTimeProvider{},
+		alerts:         make(map[string]*alertState),
+	}
+}
+
+// Alert notifies an error for the key. The first alert for a key is sent immediately,
+// repeats are suppressed until the backoff has elapsed, doubling each time up to the max backoff
+func (a *AlertManager) Alert(key, message string, err error) {
+	a.mu.Lock()
+	now := a.timeProvider.Now()
+
+	state, exists := a.alerts[key]
+	if !exists {
+		a.alerts[key] = &alertState{
+			message:   message,
+			firstSeen: now,
+			lastSent:  now,
+			backoff:   a.initialBackoff,
+		}
+		a.mu.Unlock()
+
+		a.notifier.NotifyError(message, err)
+		return
+	}
+
+	state.message = message
+	if now.Sub(state.lastSent) < state.backoff {
+		state.suppressed++
+		a.mu.Unlock()
+		return
+	}
+
+	repeated := fmt.Sprintf("%s\n(repeated %d times since %s)", message, state.suppressed+1, state.firstSeen.UTC().Format(time.RFC3339))
+	state.lastSent = now
+	state.suppressed = 0
+	state.backoff = min(state.backoff*2, a.maxBackoff)
+	a.mu.Unlock()
+
+	a.notifier.NotifyError(repeated, err)
+}
+
+// Resolve clears the alert for the key, sending a single resolved message if it was active
+func (a *AlertManager) Resolve(key, message string) {
+	a.mu.Lock()
+	state, exists := a.alerts[key]
+	if !exists {
+		a.mu.Unlock()
+		return
+	}
+	delete(a.alerts, key)
+	duration := a.timeProvider.Now().Sub(state.firstSeen).Round(time.Second)
+	a.mu.Unlock()
+
+	a.notifier.Notify(SeverityInfo, fmt.Sprintf("RESOLVED: %s\nPrevious alert: %s\n(active for %s)", message, state.message, duration))
+}
+
+// IsActive checks if there is an unresolved alert for the key
+func (a *AlertManager) IsActive(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, exists := a.alerts[key]
+	return exists
+}
@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type recordingNotifier struct {
+	messages []string
+	errors   []string
+}
+
+func (r *recordingNotifier) Notify(_ Severity, message string) {
+	r.messages = append(r.messages, message)
+}
+
+func (r *recordingNotifier) NotifyError(message string, _ error) {
+	r.errors = append(r.errors, message)
+}
+
+func TestAlertManagerBacksOffRepeats(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	n := &recordingNotifier{}
+	a := NewAlertManager(n, time.Minute, 4*time.Minute)
+	a.timeProvider = clock
+
+	err := errors.New("connection refused")
+
+	// Alert every second for 10 minutes
+	for i := 0; i < 600; i++ {
+		a.Alert("account:1:equity", "Error getting equity", err)
+		clock.now = clock.now.Add(time.Second)
+	}
+
+	// Sent at 0s, then after backoffs of 1m, 2m, 4m (capped)
+	if len(n.errors) != 4 {
+		t.Fatalf("expected 4 alerts, got %d: %v", len(n.errors), n.errors)
+	}
+	if !strings.Contains(n.errors[1], "repeated 60 times") {
+		t.Errorf("expected repeat count in alert, got %q", n.errors[1])
+	}
+
+	// A different key is not throttled by the first
+	a.Alert("account:2:equity", "Error getting equity", err)
+	if len(n.errors) != 5 {
+		t.Errorf("expected alert for new key to be sent, got %d", len(n.errors))
+	}
+}
+
+func TestAlertManagerResolve(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	n := &recordingNotifier{}
+	a := NewAlertManager(n, time.Minute, time.Hour)
+	a.timeProvider = clock
+
+	// Resolving without an active alert sends nothing
+	a.Resolve("account:1:equity", "Recovered")
+	if len(n.messages) != 0 {
+		t.Fatalf("expected no resolved message, got %v", n.messages)
+	}
+
+	a.Alert("account:1:equity", "Error getting equity", nil)
+	clock.now = clock.now.Add(90 * time.Second)
+
+	a.Resolve("account:1:equity", "Recovered")
+	a.Resolve("account:1:equity", "Recovered")
+
+	if len(n.messages) != 1 {
+		t.Fatalf("expected a single resolved message, got %v", n.messages)
+	}
+	if !strings.HasPrefix(n.messages[0], "RESOLVED: Recovered") || !strings.Contains(n.messages[0], "1m30s") {
+		t.Errorf("unexpected resolved message: %q", n.messages[0])
+	}
+	if a.IsActive("account:1:equity") {
+		t.Errorf("expected alert to be cleared")
+	}
+
+	// After resolving, the next failure alerts immediately
+	a.Alert("account:1:equity", "Error getting equity", nil)
+	if len(n.errors) != 2 {
+		t.Errorf("expected new alert after resolve, got %d", len(n.errors))
+	}
+}
@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"net/http"
+
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// DiscordNotifier sends notifications to a Discord channel webhook
+type DiscordNotifier struct {
+	client     *http.Client
+	webhookUrl string
+}
+
+func NewDiscordNotifier(webhookUrl string) *DiscordNotifier {
+	return &DiscordNotifier{
+		client:     httpClient,
+		webhookUrl: webhookUrl,
+	}
+}
+
+type DiscordBody struct {
+	Content string `json:"content"`
+}
+
+// Notify sends a message to the Discord webhook
+func (d *DiscordNotifier) Notify(severity Severity, message string) {
+	d.send(severity, message, nil)
+}
+
+// NotifyError sends an error message to the Discord webhook
+func (d *DiscordNotifier) NotifyError(message string, err error) {
+	d.send(SeverityError, message, err)
+}
+
+func (d *DiscordNotifier) send(severity Severity, message string, err error) {
+	logger.Debugf("Sending discord message: %s", message)
+
+	if err := postJSON(d.client, d.webhookUrl, DiscordBody{Content: formatText(severity, message, err)}); err != nil {
+		logger.Errorf("Error sending discord message: %v", err)
+		recordSend(ChannelDiscord, err)
+		return
+	}
+	recordSend(ChannelDiscord, nil)
+}
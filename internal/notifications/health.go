@@ -0,0 +1,67 @@
+package notifications
+
+import (
+	"sync"
+	"time"
+)
+
+// ChannelHealth is the outcome of the latest notifications sent to a channel
+type ChannelHealth struct {
+	// LastSuccessAt and LastFailureAt are zero if no notification has succeeded or failed yet
+	LastSuccessAt time.Time
+	LastFailureAt time.Time
+	LastError     string
+}
+
+// Healthy is true unless the latest notification sent to the channel failed
+func (h ChannelHealth) Healthy() bool {
+	return h.LastFailureAt.IsZero() || h.LastSuccessAt.After(h.LastFailureAt)
+}
+
+// channels tracks the health of each configured channel. Channels are webhooks that can't be probed
+// without sending a message, so reachability is inferred from the notifications sent
+var channels = struct {
+	mu     sync.Mutex
+	health map[string]ChannelHealth
+}{health: make(map[string]ChannelHealth)}
+
+// trackChannels reports the health of the configured channels, before any notification is sent to them.
+// Channels no longer configured are dropped, and the health of channels still configured is kept
+func trackChannels(configured []string) {
+	channels.mu.Lock()
+	defer channels.mu.Unlock()
+
+	health := make(map[string]ChannelHealth, len(configured))
+	for _, channel := range configured {
+		health[channel] = channels.health[channel]
+	}
+	channels.health = health
+}
+
+// recordSend records the outcome of sending a notification to the channel
+func recordSend(channel string, err error) {
+	channels.mu.Lock()
+	defer channels.mu.Unlock()
+
+	h := channels.health[channel]
+	if err != nil {
+		sendFailures.WithLabelValues(channel).Inc()
+		h.LastFailureAt = time.Now()
+		h.LastError = err.Error()
+	} else {
+		h.LastSuccessAt = time.Now()
+	}
+	channels.health[channel] = h
+}
+
+// Health returns the health of each configured channel
+func Health() map[string]ChannelHealth {
+	channels.mu.Lock()
+	defer channels.mu.Unlock()
+
+	health := make(map[string]ChannelHealth, len(channels.health))
+	for channel, h := range channels.health {
+		health[channel] = h
+	}
+	return health
+}
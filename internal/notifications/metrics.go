@@ -0,0 +1,11 @@
+package notifications
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var sendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "risk_manager_notification_send_failures_total",
+	Help: "Notifications that failed to send, by channel.",
+}, []string{"channel"})
@@ -0,0 +1,166 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// sendTimeout bounds sending a single notification. Notifications are sent from the equity check workers,
+// so a hung channel must not stall the tick
+const sendTimeout = 10 * time.Second
+
+// httpClient is the client used by all notifiers
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// Severity is the importance of a notification
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// Notifier defines the interface that all notification backends must implement.
+// Notifications are best effort, failures are logged by the backend and not returned
+type Notifier interface {
+	// Notify sends a message with the given severity
+	Notify(severity Severity, message string)
+	// NotifyError sends an error message with SeverityError, including the error details if err is not nil
+	NotifyError(message string, err error)
+}
+
+// Supported notification channels
+const (
+	ChannelTelegram = "telegram"
+	ChannelSlack    = "slack"
+	ChannelDiscord  = "discord"
+	ChannelWebhook  = "webhook"
+)
+
+// NewNotifier is a factory function that returns a notifier sending to all channels enabled in the configuration
+func NewNotifier(cfg config.NotificationsConfig) (Notifier, error) {
+	var notifiers []Notifier
+	for _, channel := range cfg.Channels {
+		switch channel {
+		case ChannelTelegram:
+			notifiers = append(notifiers, NewTelegramNotifier(&cfg.Telegram))
+		case ChannelSlack:
+			notifiers = append(notifiers, NewSlackNotifier(cfg.Slack.WebhookUrl))
+		case ChannelDiscord:
+			notifiers = append(notifiers, NewDiscordNotifier(cfg.Discord.WebhookUrl))
+		case ChannelWebhook:
+			notifiers = append(notifiers, NewWebhookNotifier(cfg.Webhook.Url))
+		default:
+			return nil, fmt.Errorf("unsupported notification channel: %s", channel)
+		}
+	}
+	trackChannels(cfg.Channels)
+
+	switch len(notifiers) {
+	case 0:
+		logger.Warnf("No notification channels configured, notifications will only be logged")
+		return LogNotifier{}, nil
+	case 1:
+		return notifiers[0], nil
+	default:
+		return NewMultiNotifier(notifiers...), nil
+	}
+}
+
+// MultiNotifier fans out notifications to multiple notifiers
+type MultiNotifier struct {
+	notifiers []Notifier
+}
+
+func NewMultiNotifier(notifiers ...Notifier) *MultiNotifier {
+	return &MultiNotifier{notifiers: notifiers}
+}
+
+func (m *MultiNotifier) Notify(severity Severity, message string) {
+	for _, n := range m.notifiers {
+		n.Notify(severity, message)
+	}
+}
+
+func (m *MultiNotifier) NotifyError(message string, err error) {
+	for _, n := range m.notifiers {
+		n.NotifyError(message, err)
+	}
+}
+
+// LogNotifier only logs notifications. Used when no notification channels are configured
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(severity Severity, message string) {
+	logger.Infof("Notification [%s]: %s", severity, message)
+}
+
+func (LogNotifier) NotifyError(message string, err error) {
+	logger.Infof("Notification [%s]: %s: %v", SeverityError, message, err)
+}
+
+// formatText formats a notification as plain text, for backends without rich formatting
+func formatText(severity Severity, message string, err error) string {
+	text := fmt.Sprintf("[GO-RMS] %s\n%s", strings.ToUpper(severity.String()), message)
+	if err != nil {
+		text += fmt.Sprintf("\n%v", err)
+	}
+	return text
+}
+
+// postJSON posts the body as JSON to the url, returning an error on any non 2xx response
+func postJSON(client *http.Client, url string, body any) error {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error marshalling body: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("error creating http request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("error reading response body: %v", err)
+		}
+
+		return fmt.Errorf("unexpected status code: %d: res: %s", resp.StatusCode, string(b))
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// captureServer returns a test server that records the decoded JSON body of each request
+func captureServer(t *testing.T, status int) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+
+	var bodies []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(b, &body); err != nil {
+			t.Errorf("invalid JSON body: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &bodies
+}
+
+func TestSlackNotifier(t *testing.T) {
+	logger.InitLogger()
+	server, bodies := captureServer(t, http.StatusOK)
+
+	NewSlackNotifier(server.URL).NotifyError("Something failed", errors.New("boom"))
+
+	if len(*bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*bodies))
+	}
+	text, _ := (*bodies)[0]["text"].(string)
+	if !strings.Contains(text, "ERROR") || !strings.Contains(text, "Something failed") || !strings.Contains(text, "boom") {
+		t.Errorf("unexpected slack text: %q", text)
+	}
+}
+
+func TestDiscordNotifier(t *testing.T) {
+	logger.InitLogger()
+	server, bodies := captureServer(t, http.StatusNoContent)
+
+	NewDiscordNotifier(server.URL).Notify(SeverityCritical, "Breach")
+
+	if len(*bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*bodies))
+	}
+	content, _ := (*bodies)[0]["content"].(string)
+	if !strings.Contains(content, "CRITICAL") || !strings.Contains(content, "Breach") {
+		t.Errorf("unexpected discord content: %q", content)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	logger.InitLogger()
+	server, bodies := captureServer(t, http.StatusOK)
+
+	NewWebhookNotifier(server.URL).Notify(SeverityWarning, "Approaching limit")
+
+	if len(*bodies) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(*bodies))
+	}
+	body := (*bodies)[0]
+	if body["severity"] != "warning" || body["message"] != "Approaching limit" || body["source"] != "GO-RMS" {
+		t.Errorf("unexpected webhook body: %v", body)
+	}
+	if _, exists := body["error"]; exists {
+		t.Errorf("expected no error field, got %v", body["error"])
+	}
+}
+
+func TestWebhookNotifierRecordsHealth(t *testing.T) {
+	logger.InitLogger()
+	server, _ := captureServer(t, http.StatusInternalServerError)
+
+	before := testutil.ToFloat64(sendFailures.WithLabelValues(ChannelWebhook))
+	NewWebhookNotifier(server.URL).Notify(SeverityWarning, "Approaching limit")
+
+	if n := testutil.ToFloat64(sendFailures.WithLabelValues(ChannelWebhook)) - before; n != 1 {
+		t.Errorf("expected 1 send failure, got %v", n)
+	}
+	if h := Health()[ChannelWebhook]; h.Healthy() || h.LastError == "" {
+		t.Errorf("expected webhook to be unhealthy after a failure, got %+v", h)
+	}
+
+	server, _ = captureServer(t, http.StatusOK)
+	NewWebhookNotifier(server.URL).Notify(SeverityWarning, "Approaching limit")
+
+	if h := Health()[ChannelWebhook]; !h.Healthy() {
+		t.Errorf("expected webhook to be healthy after a success, got %+v", h)
+	}
+}
+
+func TestNotifierTimesOutHungChannel(t *testing.T) {
+	logger.InitLogger()
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	slack := NewSlackNotifier(server.URL)
+	if slack.client.Timeout <= 0 {
+		t.Fatalf("expected the notifier client to have a timeout")
+	}
+	slack.client = &http.Client{Timeout: 50 * time.Millisecond}
+
+	done := make(chan struct{})
+	go func() {
+		slack.Notify(SeverityCritical, "Breach")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the notification to time out rather than block")
+	}
+	if h := Health()[ChannelSlack]; h.Healthy() {
+		t.Errorf("expected slack to be unhealthy after a timeout, got %+v", h)
+	}
+}
+
+func TestTelegramMessageEscapesHtml(t *testing.T) {
+	breach := rules.Breach{
+		Result:     rules.Result{Rule: rules.DailyLoss, Equity: 94000, Limit: 95000, Threshold: 5},
+		BrokerName: "<ftmo & co>",
+	}
+
+	got := telegramMessage(SeverityCritical, "RISK BREACH: "+breach.String())
+	want := "[GO-RMS] CRITICAL 🛑\nRISK BREACH: Rule &#39;daily_loss&#39; breached for broker &lt;ftmo &amp; co&gt;: equity 94000.00 at or below limit 95000.00 (threshold 5.00%)\n"
+	if got != want {
+		t.Errorf("telegramMessage() = %q, want %q", got, want)
+	}
+
+	got = telegramErrorMessage("Error flattening <ftmo>", errors.New("status 400: <html>"))
+	want = "[GO-RMS] ERROR ⚠️\n<b>Error:</b> Error flattening &lt;ftmo&gt;\n<pre>status 400: &lt;html&gt;</pre>\n"
+	if got != want {
+		t.Errorf("telegramErrorMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	logger.InitLogger()
+	slack, slackBodies := captureServer(t, http.StatusOK)
+	discord, discordBodies := captureServer(t, http.StatusOK)
+
+	n, err := NewNotifier(config.NotificationsConfig{
+		Channels: []string{ChannelSlack, ChannelDiscord},
+		Slack:    config.SlackConfig{WebhookUrl: slack.URL},
+		Discord:  config.DiscordConfig{WebhookUrl: discord.URL},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	n.Notify(SeverityInfo, "Fan out")
+
+	if len(*slackBodies) != 1 || len(*discordBodies) != 1 {
+		t.Errorf("expected notification on every channel, got slack=%d discord=%d", len(*slackBodies), len(*discordBodies))
+	}
+
+	if _, err := NewNotifier(config.NotificationsConfig{Channels: []string{"pager"}}); err == nil {
+		t.Errorf("expected error for unsupported channel")
+	}
+
+	n, err = NewNotifier(config.NotificationsConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := n.(LogNotifier); !ok {
+		t.Errorf("expected log notifier when no channels are configured, got %T", n)
+	}
+}
+
+func TestSwitchNotifier(t *testing.T) {
+	before, after := &recordingNotifier{}, &recordingNotifier{}
+	s := NewSwitchNotifier(before)
+
+	s.Notify(SeverityInfo, "First")
+	s.Swap(after)
+	s.Notify(SeverityInfo, "Second")
+	s.NotifyError("Third", nil)
+
+	if len(before.messages) != 1 || len(after.messages) != 1 || len(after.errors) != 1 {
+		t.Errorf("expected notifications to follow the swap, got before=%v after=%v/%v", before.messages, after.messages, after.errors)
+	}
+}
@@ -0,0 +1,45 @@
+package notifications
+
+import (
+	"net/http"
+
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// SlackNotifier sends notifications to a Slack incoming webhook
+type SlackNotifier struct {
+	client     *http.Client
+	webhookUrl string
+}
+
+func NewSlackNotifier(webhookUrl string) *SlackNotifier {
+	return &SlackNotifier{
+		client:     httpClient,
+		webhookUrl: webhookUrl,
+	}
+}
+
+type SlackBody struct {
+	Text string `json:"text"`
+}
+
+// Notify sends a message to the Slack webhook
+func (s *SlackNotifier) Notify(severity Severity, message string) {
+	s.send(severity, message, nil)
+}
+
+// NotifyError sends an error message to the Slack webhook
+func (s *SlackNotifier) NotifyError(message string, err error) {
+	s.send(SeverityError, message, err)
+}
+
+func (s *SlackNotifier) send(severity Severity, message string, err error) {
+	logger.Debugf("Sending slack message: %s", message)
+
+	if err := postJSON(s.client, s.webhookUrl, SlackBody{Text: formatText(severity, message, err)}); err != nil {
+		logger.Errorf("Error sending slack message: %v", err)
+		recordSend(ChannelSlack, err)
+		return
+	}
+	recordSend(ChannelSlack, nil)
+}
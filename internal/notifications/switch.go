@@ -0,0 +1,35 @@
+package notifications
+
+import "sync"
+
+// SwitchNotifier forwards notifications to a notifier that can be replaced while the service runs,
+// e.g. when the notification channels are reloaded
+type SwitchNotifier struct {
+	mu       sync.RWMutex
+	notifier Notifier
+}
+
+func NewSwitchNotifier(notifier Notifier) *SwitchNotifier {
+	return &SwitchNotifier{notifier: notifier}
+}
+
+// Swap replaces the notifier that notifications are forwarded to
+func (s *SwitchNotifier) Swap(notifier Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = notifier
+}
+
+func (s *SwitchNotifier) Notify(severity Severity, message string) {
+	s.mu.RLock()
+	n := s.notifier
+	s.mu.RUnlock()
+	n.Notify(severity, message)
+}
+
+func (s *SwitchNotifier) NotifyError(message string, err error) {
+	s.mu.RLock()
+	n := s.notifier
+	s.mu.RUnlock()
+	n.NotifyError(message, err)
+}
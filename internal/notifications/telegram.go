@@ -1,23 +1,22 @@
 package notifications
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"github.com/jwtly10/at4j-risk-manager/internal/config"
 	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
-	"io"
+	"html"
 	"net/http"
 )
 
 const TELEGRAM_URL = "https://api.telegram.org/bot"
 
 type TelegramNotifier struct {
-	cfg *config.TelegramConfig
+	client *http.Client
+	cfg    *config.TelegramConfig
 }
 
 func NewTelegramNotifier(cfg *config.TelegramConfig) *TelegramNotifier {
-	return &TelegramNotifier{cfg: cfg}
+	return &TelegramNotifier{client: httpClient, cfg: cfg}
 }
 
 type TelegramBody struct {
@@ -28,85 +27,82 @@ type TelegramBody struct {
 
 // NotifyError sends an error message to a telegram chat, formatted in HTML.
 func (t *TelegramNotifier) NotifyError(message string, err error) {
+	err = notifyHtml(t.client, t.cfg.Token, t.cfg.ChatId, telegramErrorMessage(message, err))
+	recordSend(ChannelTelegram, err)
+	// If we fail, there's nothing to handle really so just log and continue
+	if err != nil {
+		logger.Errorf("Error sending telegram message: %v", err)
+	}
+}
 
-	htmlMessage := ""
+// Notify sends a generic message to a telegram chat, formatted in HTML.
+func (t *TelegramNotifier) Notify(severity Severity, message string) {
+	err := notifyHtml(t.client, t.cfg.Token, t.cfg.ChatId, telegramMessage(severity, message))
+	recordSend(ChannelTelegram, err)
+	if err != nil {
+		logger.Errorf("Error sending telegram message: %v", err)
+	}
+}
+
+// telegramErrorMessage formats an error message in HTML. The message and error are escaped, as Telegram rejects
+// messages with unsupported tags, e.g. the < of a breach
+func telegramErrorMessage(message string, err error) string {
 	if err == nil {
-		htmlMessage = fmt.Sprintf(
+		return fmt.Sprintf(
 			"[GO-RMS] ERROR ⚠️\n"+
 				"<b>Error:</b> %s\n",
-			message,
-		)
-	} else {
-		htmlMessage = fmt.Sprintf(
-			"[GO-RMS] ERROR ⚠️\n"+
-				"<b>Error:</b> %s\n"+
-				"<pre>%v</pre>\n",
-			message,
-			err,
+			html.EscapeString(message),
 		)
 	}
-
-	err = notifyHtml(t.cfg.Token, t.cfg.ChatId, htmlMessage)
-	// If we fail, there's nothing to handle really so just log and continue
-	if err != nil {
-		logger.Errorf("Error sending telegram message: %v", err)
-	}
+	return fmt.Sprintf(
+		"[GO-RMS] ERROR ⚠️\n"+
+			"<b>Error:</b> %s\n"+
+			"<pre>%s</pre>\n",
+		html.EscapeString(message),
+		html.EscapeString(err.Error()),
+	)
 }
 
-// Notify sends a generic message to a telegram chat, formatted in HTML.
-func (t *TelegramNotifier) Notify(message string) {
-	htmlMessage := fmt.Sprintf(
-		"[GO-RMS] 🚨\n"+
+// telegramMessage formats a message of the severity in HTML, escaping the message
+func telegramMessage(severity Severity, message string) string {
+	return fmt.Sprintf(
+		"[GO-RMS] %s\n"+
 			"%s\n",
-		message,
+		telegramHeader(severity),
+		html.EscapeString(message),
 	)
-	err := notifyHtml(t.cfg.Token, t.cfg.ChatId, htmlMessage)
-	if err != nil {
-		logger.Errorf("Error sending telegram message: %v", err)
+}
+
+// telegramHeader returns the message header for a severity
+func telegramHeader(severity Severity) string {
+	switch severity {
+	case SeverityWarning:
+		return "WARNING ⚠️"
+	case SeverityError:
+		return "ERROR ⚠️"
+	case SeverityCritical:
+		return "CRITICAL 🛑"
+	default:
+		return "🚨"
 	}
 }
 
 // notifyHTML sends a message to a telegram chat using the HTML parse mode.
-func notifyHtml(token, chatId, message string) error {
+func notifyHtml(client *http.Client, token, chatId, message string) error {
 	logger.Debugf("Sending telegram message: %s", message)
 
 	url := TELEGRAM_URL + token + "/sendMessage"
 
-	client := http.DefaultClient
-
 	body := TelegramBody{
 		ChatId:    chatId,
 		ParseMode: "HTML",
 		Text:      message,
 	}
 
-	bodyBytes, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("error marshalling telegram body: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return fmt.Errorf("error creating http request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
+	if err := postJSON(client, url, body); err != nil {
 		return fmt.Errorf("error sending telegram message: %v", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
-
-		b, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("error reading response body: %v", err)
-		}
-
-		return fmt.Errorf("unexpected status code from telegram: %d: res: %s", resp.StatusCode, string(b))
-	}
-
 	logger.Debugf("Telegram message sent successfully")
 
 	return nil
@@ -53,7 +53,7 @@ func TestTelegramNotifier_Notify(t *testing.T) {
 		},
 	}
 
-	n.Notify(fmt.Sprintf("Equity updated for broker %s: %.2f", "Some name", 21939.32))
+	n.Notify(SeverityInfo, fmt.Sprintf("Equity updated for broker %s: %.2f", "Some name", 21939.32))
 }
 
 func TestTelegramNotifier_BadAuth(t *testing.T) {
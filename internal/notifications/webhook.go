@@ -0,0 +1,60 @@
+package notifications
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/pkg/logger"
+)
+
+// WebhookNotifier posts notifications as JSON to a generic webhook
+type WebhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		client: httpClient,
+		url:    url,
+	}
+}
+
+type WebhookBody struct {
+	Source    string    `json:"source"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notify posts a message to the webhook
+func (wh *WebhookNotifier) Notify(severity Severity, message string) {
+	wh.send(severity, message, nil)
+}
+
+// NotifyError posts an error message to the webhook
+func (wh *WebhookNotifier) NotifyError(message string, err error) {
+	wh.send(SeverityError, message, err)
+}
+
+func (wh *WebhookNotifier) send(severity Severity, message string, err error) {
+	logger.Debugf("Sending webhook message: %s", message)
+
+	body := WebhookBody{
+		Source:    "GO-RMS",
+		Severity:  severity.String(),
+		Message:   message,
+		Timestamp: time.Now().UTC(),
+	}
+	if err != nil {
+		body.Error = err.Error()
+	}
+
+	if err := postJSON(wh.client, wh.url, body); err != nil {
+		logger.Errorf("Error sending webhook message: %v", err)
+		recordSend(ChannelWebhook, err)
+		return
+	}
+	recordSend(ChannelWebhook, nil)
+}
@@ -0,0 +1,232 @@
+package profiles
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+)
+
+// Supported drawdown modes of a profile's max loss limit
+const (
+	// DrawdownStatic measures max loss from the account's initial balance
+	DrawdownStatic = "STATIC"
+	// DrawdownEndOfDay trails max loss from the highest equity recorded at the daily reset
+	DrawdownEndOfDay = rules.TrailingEndOfDay
+	// DrawdownIntraday trails max loss from the highest equity seen at any point
+	DrawdownIntraday = rules.TrailingIntraday
+)
+
+// Names of the built-in profiles
+const (
+	Default    = "DEFAULT"
+	FTMO       = "FTMO"
+	The5ers    = "THE5ERS"
+	FundedNext = "FUNDEDNEXT"
+)
+
+// Profile bundles the trading day and risk parameters of a prop firm
+type Profile struct {
+	Name              string
+	Timezone          string
+	DailyUpdateHour   int // 0 - 23
+	DailyUpdateMinute int // 0 - 59
+	// DailyLossPercent is the max percentage of the day start equity that can be lost in a day. 0 disables the rule
+	DailyLossPercent float64
+	// MaxLossPercent is the max percentage that can be lost overall, measured according to DrawdownMode. 0 disables the rule
+	MaxLossPercent float64
+	// ProfitTargetPercent is the profit over the initial balance required to pass, reported as progress by the status API.
+	// 0 if there is no target
+	ProfitTargetPercent float64
+	// MinTradingDays is the minimum number of days that must be traded to pass, reported by the status API. 0 if there is no minimum
+	MinTradingDays int
+	DrawdownMode   string
+}
+
+// Overrides are per account replacements of profile parameters. Nil fields keep the profile value
+type Overrides struct {
+	Timezone            *string
+	DailyUpdateHour     *int
+	DailyUpdateMinute   *int
+	DailyLossPercent    *float64
+	MaxLossPercent      *float64
+	ProfitTargetPercent *float64
+	MinTradingDays      *int
+	DrawdownMode        *string
+}
+
+// Assignment links a broker account to a profile by name
+type Assignment struct {
+	ProfileName string
+	Overrides   Overrides
+}
+
+// Catalogue is the set of known profiles, keyed by name
+type Catalogue map[string]Profile
+
+// Presets returns the built-in profiles.
+//
+// Values reflect the standard 2-step challenge of each firm and should be checked against
+// the firm's current rules, using per account overrides where they differ
+func Presets() Catalogue {
+	return Catalogue{
+		Default: {
+			Name:              Default,
+			Timezone:          "UTC",
+			DailyUpdateHour:   00,
+			DailyUpdateMinute: 1,
+			DrawdownMode:      DrawdownStatic,
+		},
+		FTMO: {
+			Name:                FTMO,
+			Timezone:            "Europe/Prague",
+			DailyUpdateHour:     00,
+			DailyUpdateMinute:   1,
+			DailyLossPercent:    5,
+			MaxLossPercent:      10,
+			ProfitTargetPercent: 10,
+			MinTradingDays:      4,
+			DrawdownMode:        DrawdownStatic,
+		},
+		The5ers: {
+			Name:                The5ers,
+			Timezone:            "Europe/Athens",
+			DailyUpdateHour:     00,
+			DailyUpdateMinute:   1,
+			DailyLossPercent:    5,
+			MaxLossPercent:      10,
+			ProfitTargetPercent: 8,
+			MinTradingDays:      3,
+			DrawdownMode:        DrawdownStatic,
+		},
+		FundedNext: {
+			Name:                FundedNext,
+			Timezone:            "Europe/Athens",
+			DailyUpdateHour:     00,
+			DailyUpdateMinute:   1,
+			DailyLossPercent:    5,
+			MaxLossPercent:      10,
+			ProfitTargetPercent: 8,
+			MinTradingDays:      5,
+			DrawdownMode:        DrawdownStatic,
+		},
+	}
+}
+
+// Load returns the built-in profiles with the configured custom profiles added,
+// replacing any built-in profile of the same name
+func Load(custom []config.ProfileConfig) (Catalogue, error) {
+	c := Presets()
+	for _, cfg := range custom {
+		p := Profile{
+			Name:                cfg.Name,
+			Timezone:            cfg.Timezone,
+			DailyUpdateHour:     cfg.DailyUpdateHour,
+			DailyUpdateMinute:   cfg.DailyUpdateMinute,
+			DailyLossPercent:    cfg.DailyLossPercent,
+			MaxLossPercent:      cfg.MaxLossPercent,
+			ProfitTargetPercent: cfg.ProfitTargetPercent,
+			MinTradingDays:      cfg.MinTradingDays,
+			DrawdownMode:        cfg.DrawdownMode,
+		}
+		if p.Timezone == "" {
+			p.Timezone = "UTC"
+		}
+		if p.DrawdownMode == "" {
+			p.DrawdownMode = DrawdownStatic
+		}
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+		c[p.Name] = p
+	}
+	return c, nil
+}
+
+// Resolve returns the named profile with the given overrides applied
+func (c Catalogue) Resolve(name string, overrides Overrides) (Profile, error) {
+	p, exists := c[name]
+	if !exists {
+		return Profile{}, fmt.Errorf("unknown profile: %s", name)
+	}
+
+	p = p.Apply(overrides)
+	if err := p.Validate(); err != nil {
+		return Profile{}, err
+	}
+
+	return p, nil
+}
+
+// Apply returns a copy of the profile with any non nil overrides applied
+func (p Profile) Apply(o Overrides) Profile {
+	if o.Timezone != nil {
+		p.Timezone = *o.Timezone
+	}
+	if o.DailyUpdateHour != nil {
+		p.DailyUpdateHour = *o.DailyUpdateHour
+	}
+	if o.DailyUpdateMinute != nil {
+		p.DailyUpdateMinute = *o.DailyUpdateMinute
+	}
+	if o.DailyLossPercent != nil {
+		p.DailyLossPercent = *o.DailyLossPercent
+	}
+	if o.MaxLossPercent != nil {
+		p.MaxLossPercent = *o.MaxLossPercent
+	}
+	if o.ProfitTargetPercent != nil {
+		p.ProfitTargetPercent = *o.ProfitTargetPercent
+	}
+	if o.MinTradingDays != nil {
+		p.MinTradingDays = *o.MinTradingDays
+	}
+	if o.DrawdownMode != nil {
+		p.DrawdownMode = *o.DrawdownMode
+	}
+	return p
+}
+
+// Validate checks the profile parameters are usable by the tracker and rule engine
+func (p Profile) Validate() error {
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %s for profile %s: %v", p.Timezone, p.Name, err)
+	}
+	if p.DailyUpdateHour < 0 || p.DailyUpdateHour > 23 {
+		return fmt.Errorf("invalid daily update hour %d for profile %s", p.DailyUpdateHour, p.Name)
+	}
+	if p.DailyUpdateMinute < 0 || p.DailyUpdateMinute > 59 {
+		return fmt.Errorf("invalid daily update minute %d for profile %s", p.DailyUpdateMinute, p.Name)
+	}
+	if p.DailyLossPercent < 0 || p.DailyLossPercent >= 100 {
+		return fmt.Errorf("invalid daily loss percent %.2f for profile %s", p.DailyLossPercent, p.Name)
+	}
+	if p.MaxLossPercent < 0 || p.MaxLossPercent >= 100 {
+		return fmt.Errorf("invalid max loss percent %.2f for profile %s", p.MaxLossPercent, p.Name)
+	}
+
+	switch p.DrawdownMode {
+	case DrawdownStatic, DrawdownEndOfDay, DrawdownIntraday:
+	default:
+		return fmt.Errorf("unsupported drawdown mode %s for profile %s", p.DrawdownMode, p.Name)
+	}
+
+	return nil
+}
+
+// Rules returns the risk rules enabled by the profile
+func (p Profile) Rules() []rules.Rule {
+	var r []rules.Rule
+	if p.DailyLossPercent > 0 {
+		r = append(r, rules.DailyLossRule{MaxLossPercent: p.DailyLossPercent})
+	}
+	if p.MaxLossPercent > 0 {
+		if p.DrawdownMode == DrawdownStatic {
+			r = append(r, rules.MaxLossRule{MaxLossPercent: p.MaxLossPercent})
+		} else {
+			r = append(r, rules.TrailingDrawdownRule{MaxLossPercent: p.MaxLossPercent, Mode: p.DrawdownMode})
+		}
+	}
+	return r
+}
@@ -0,0 +1,96 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/jwtly10/at4j-risk-manager/internal/config"
+	"github.com/jwtly10/at4j-risk-manager/internal/rules"
+)
+
+func TestPresetsAreValid(t *testing.T) {
+	for name, p := range Presets() {
+		if p.Name != name {
+			t.Errorf("profile %s has mismatched name %s", name, p.Name)
+		}
+		if err := p.Validate(); err != nil {
+			t.Errorf("preset %s is invalid: %v", name, err)
+		}
+	}
+}
+
+func TestResolveAppliesOverrides(t *testing.T) {
+	dailyLoss := 4.0
+	mode := DrawdownIntraday
+
+	p, err := Presets().Resolve(FTMO, Overrides{DailyLossPercent: &dailyLoss, DrawdownMode: &mode})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.DailyLossPercent != 4 {
+		t.Errorf("DailyLossPercent = %.2f, want 4", p.DailyLossPercent)
+	}
+	if p.MaxLossPercent != 10 || p.Timezone != "Europe/Prague" {
+		t.Errorf("expected remaining FTMO parameters to be kept, got %+v", p)
+	}
+
+	expected := []rules.Rule{
+		rules.DailyLossRule{MaxLossPercent: 4},
+		rules.TrailingDrawdownRule{MaxLossPercent: 10, Mode: rules.TrailingIntraday},
+	}
+	r := p.Rules()
+	if len(r) != len(expected) {
+		t.Fatalf("expected %d rules, got %+v", len(expected), r)
+	}
+	for i := range expected {
+		if r[i] != expected[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, r[i], expected[i])
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	if _, err := Presets().Resolve("UNKNOWN", Overrides{}); err == nil {
+		t.Errorf("expected error for unknown profile")
+	}
+
+	tz := "Not/AZone"
+	if _, err := Presets().Resolve(FTMO, Overrides{Timezone: &tz}); err == nil {
+		t.Errorf("expected error for invalid timezone override")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		custom  []config.ProfileConfig
+		wantErr bool
+	}{
+		{name: "Presets only"},
+		{name: "Custom profile", custom: []config.ProfileConfig{{Name: "MYFIRM", DailyLossPercent: 4, MaxLossPercent: 8}}},
+		{name: "Replaces preset", custom: []config.ProfileConfig{{Name: FTMO, Timezone: "Europe/Prague", DailyLossPercent: 3}}},
+		{name: "Invalid timezone", custom: []config.ProfileConfig{{Name: "MYFIRM", Timezone: "Mars/Olympus"}}, wantErr: true},
+		{name: "Invalid drawdown mode", custom: []config.ProfileConfig{{Name: "MYFIRM", DrawdownMode: "WEEKLY"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Load(tt.custom)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(c) < len(Presets()) {
+				t.Errorf("expected the presets to be kept, got %d profiles", len(c))
+			}
+			for _, cfg := range tt.custom {
+				p := c[cfg.Name]
+				if p.DailyLossPercent != cfg.DailyLossPercent || p.Timezone == "" || p.DrawdownMode == "" {
+					t.Errorf("unexpected profile %+v", p)
+				}
+			}
+		})
+	}
+}
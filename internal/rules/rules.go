@@ -0,0 +1,253 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot is the state of a broker account that rules are evaluated against
+type Snapshot struct {
+	AccountID  int64
+	BrokerName string
+	// Equity is the live equity of the account as returned by the broker
+	Equity float64
+	// DayStartEquity is the equity recorded at the start of the current trading day. 0 if not yet recorded
+	DayStartEquity float64
+	// InitialBalance is the starting balance of the account
+	InitialBalance float64
+	// HighWaterMark is the highest end of day equity recorded for the account
+	HighWaterMark float64
+	// IntradayHighWaterMark is the highest equity observed for the account, including live equity
+	IntradayHighWaterMark float64
+	// Time is the time the live equity was fetched
+	Time time.Time
+}
+
+// Rule defines a single risk limit that can be evaluated against an account snapshot
+type Rule interface {
+	// Name returns the unique identifier of the rule
+	Name() string
+	// Evaluate checks the snapshot against the rule and returns the result
+	Evaluate(s Snapshot) Result
+}
+
+// Result is the outcome of evaluating a rule against a snapshot
+type Result struct {
+	Rule string
+	// Threshold is the configured limit of the rule, as a percentage
+	Threshold float64
+	// Limit is the equity level at which the rule is breached
+	Limit float64
+	// Equity is the observed equity the rule was evaluated against
+	Equity float64
+	// UsedPercent is how much of the allowed loss has been used, as a percentage. 100 or more is a breach
+	UsedPercent float64
+	Breached    bool
+}
+
+// Breach is raised when a rule evaluation crosses its limit
+type Breach struct {
+	Result
+	AccountID  int64
+	BrokerName string
+	Time       time.Time
+}
+
+func (b Breach) String() string {
+	return fmt.Sprintf("Rule '%s' breached for broker %s: equity %.2f at or below limit %.2f (threshold %.2f%%)",
+		b.Rule, b.BrokerName, b.Equity, b.Limit, b.Threshold)
+}
+
+// Evaluate runs all rules against the snapshot and returns any breaches
+func Evaluate(s Snapshot, rules []Rule) []Breach {
+	var breaches []Breach
+	for _, rule := range rules {
+		res := rule.Evaluate(s)
+		if !res.Breached {
+			continue
+		}
+		breaches = append(breaches, Breach{
+			Result:     res,
+			AccountID:  s.AccountID,
+			BrokerName: s.BrokerName,
+			Time:       s.Time,
+		})
+	}
+	return breaches
+}
+
+// Warning is raised when a rule has used at least Level percent of its allowed loss, without breaching
+type Warning struct {
+	Result
+	// Level is the highest warning level crossed, as a percentage of the allowed loss
+	Level      float64
+	AccountID  int64
+	BrokerName string
+	Time       time.Time
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%.0f%% of rule '%s' used for broker %s: equity %.2f, limit %.2f (threshold %.2f%%)",
+		w.Level, w.Rule, w.BrokerName, w.Equity, w.Limit, w.Threshold)
+}
+
+// EvaluateWarnings runs all rules against the snapshot and returns a warning at the highest
+// of the levels crossed by each rule that has not breached. Levels must be in ascending order
+func EvaluateWarnings(s Snapshot, rules []Rule, levels []float64) []Warning {
+	var warnings []Warning
+	for _, rule := range rules {
+		res := rule.Evaluate(s)
+		if res.Breached {
+			continue
+		}
+
+		crossed := 0.0
+		for _, level := range levels {
+			if res.UsedPercent >= level {
+				crossed = level
+			}
+		}
+		if crossed == 0 {
+			continue
+		}
+
+		warnings = append(warnings, Warning{
+			Result:     res,
+			Level:      crossed,
+			AccountID:  s.AccountID,
+			BrokerName: s.BrokerName,
+			Time:       s.Time,
+		})
+	}
+	return warnings
+}
+
+// usedPercent returns how much of the allowed loss from the reference equity down to the limit has been used, as a percentage
+func usedPercent(reference, limit, equity float64) float64 {
+	allowed := reference - limit
+	if allowed <= 0 {
+		return 0
+	}
+	return max(0, (reference-equity)/allowed*100)
+}
+
+const DailyLoss = "daily_loss"
+
+// DailyLossRule is breached when equity drops more than MaxLossPercent below the day's starting equity
+type DailyLossRule struct {
+	MaxLossPercent float64
+}
+
+func (r DailyLossRule) Name() string {
+	return DailyLoss
+}
+
+func (r DailyLossRule) Evaluate(s Snapshot) Result {
+	// Without a baseline for the day there is nothing to compare against
+	if s.DayStartEquity <= 0 {
+		return Result{Rule: r.Name(), Threshold: r.MaxLossPercent, Equity: s.Equity}
+	}
+
+	limit := s.DayStartEquity * (1 - r.MaxLossPercent/100)
+	return Result{
+		Rule:        r.Name(),
+		Threshold:   r.MaxLossPercent,
+		Limit:       limit,
+		Equity:      s.Equity,
+		UsedPercent: usedPercent(s.DayStartEquity, limit, s.Equity),
+		Breached:    s.Equity <= limit,
+	}
+}
+
+const MaxLoss = "max_loss"
+
+// MaxLossRule is breached when equity drops more than MaxLossPercent below the account's initial balance
+type MaxLossRule struct {
+	MaxLossPercent float64
+}
+
+func (r MaxLossRule) Name() string {
+	return MaxLoss
+}
+
+func (r MaxLossRule) Evaluate(s Snapshot) Result {
+	limit := s.InitialBalance * (1 - r.MaxLossPercent/100)
+	return Result{
+		Rule:        r.Name(),
+		Threshold:   r.MaxLossPercent,
+		Limit:       limit,
+		Equity:      s.Equity,
+		UsedPercent: usedPercent(s.InitialBalance, limit, s.Equity),
+		Breached:    s.Equity <= limit,
+	}
+}
+
+const TrailingDrawdown = "trailing_drawdown"
+
+// Supported modes of following the high-water mark for trailing drawdown
+const (
+	// TrailingEndOfDay trails the highest equity recorded at the daily reset
+	TrailingEndOfDay = "END_OF_DAY"
+	// TrailingIntraday trails the highest equity seen at any point, including live equity
+	TrailingIntraday = "INTRADAY"
+)
+
+// TrailingDrawdownRule is breached when equity drops more than MaxLossPercent below the high-water mark.
+// The high-water mark never falls below the initial balance
+type TrailingDrawdownRule struct {
+	MaxLossPercent float64
+	Mode           string
+}
+
+func (r TrailingDrawdownRule) Name() string {
+	return TrailingDrawdown
+}
+
+func (r TrailingDrawdownRule) Evaluate(s Snapshot) Result {
+	hwm := s.HighWaterMark
+	if r.Mode == TrailingIntraday {
+		hwm = max(hwm, s.IntradayHighWaterMark, s.Equity)
+	}
+	hwm = max(hwm, s.InitialBalance)
+
+	limit := hwm * (1 - r.MaxLossPercent/100)
+	return Result{
+		Rule:        r.Name(),
+		Threshold:   r.MaxLossPercent,
+		Limit:       limit,
+		Equity:      s.Equity,
+		UsedPercent: usedPercent(hwm, limit, s.Equity),
+		Breached:    s.Equity <= limit,
+	}
+}
+
+// Config is the persisted configuration of a rule attached to a broker account
+type Config struct {
+	Type         string
+	LimitPercent float64
+	// TrailingMode is only used by trailing drawdown rules
+	TrailingMode string
+}
+
+// NewRule builds a rule from its configuration
+func NewRule(cfg Config) (Rule, error) {
+	if cfg.LimitPercent <= 0 || cfg.LimitPercent >= 100 {
+		return nil, fmt.Errorf("invalid limit percent %.2f for rule %s", cfg.LimitPercent, cfg.Type)
+	}
+
+	switch cfg.Type {
+	case DailyLoss:
+		return DailyLossRule{MaxLossPercent: cfg.LimitPercent}, nil
+	case MaxLoss:
+		return MaxLossRule{MaxLossPercent: cfg.LimitPercent}, nil
+	case TrailingDrawdown:
+		switch cfg.TrailingMode {
+		case TrailingEndOfDay, TrailingIntraday:
+			return TrailingDrawdownRule{MaxLossPercent: cfg.LimitPercent, Mode: cfg.TrailingMode}, nil
+		default:
+			return nil, fmt.Errorf("unsupported trailing mode: %s", cfg.TrailingMode)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rule type: %s", cfg.Type)
+	}
+}
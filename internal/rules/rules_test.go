@@ -0,0 +1,209 @@
+package rules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyLossRule(t *testing.T) {
+	tests := []struct {
+		name          string
+		dayStart      float64
+		equity        float64
+		maxLoss       float64
+		expectedLimit float64
+		expected      bool
+	}{
+		{
+			name:          "Equity above limit",
+			dayStart:      100000,
+			equity:        96000,
+			maxLoss:       5,
+			expectedLimit: 95000,
+			expected:      false,
+		},
+		{
+			name:          "Equity exactly at limit",
+			dayStart:      100000,
+			equity:        95000,
+			maxLoss:       5,
+			expectedLimit: 95000,
+			expected:      true,
+		},
+		{
+			name:          "Equity below limit",
+			dayStart:      100000,
+			equity:        94000,
+			maxLoss:       5,
+			expectedLimit: 95000,
+			expected:      true,
+		},
+		{
+			name:          "Equity in profit",
+			dayStart:      100000,
+			equity:        101000,
+			maxLoss:       5,
+			expectedLimit: 95000,
+			expected:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := DailyLossRule{MaxLossPercent: tt.maxLoss}
+			res := rule.Evaluate(Snapshot{Equity: tt.equity, DayStartEquity: tt.dayStart})
+			if res.Breached != tt.expected {
+				t.Errorf("Breached = %v, want %v", res.Breached, tt.expected)
+			}
+			if res.Limit != tt.expectedLimit {
+				t.Errorf("Limit = %.2f, want %.2f", res.Limit, tt.expectedLimit)
+			}
+		})
+	}
+}
+
+func TestEvaluateReturnsOnlyBreaches(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := Snapshot{AccountID: 1, BrokerName: "test", Equity: 96000, DayStartEquity: 100000, Time: now}
+
+	breaches := Evaluate(s, []Rule{
+		DailyLossRule{MaxLossPercent: 5},
+		DailyLossRule{MaxLossPercent: 3},
+	})
+
+	if len(breaches) != 1 {
+		t.Fatalf("expected 1 breach, got %d", len(breaches))
+	}
+	if breaches[0].Threshold != 3 {
+		t.Errorf("expected breach of 3%% rule, got %.2f%%", breaches[0].Threshold)
+	}
+	if breaches[0].AccountID != 1 || !breaches[0].Time.Equal(now) {
+		t.Errorf("breach not populated from snapshot: %+v", breaches[0])
+	}
+}
+
+func TestEvaluateWarnings(t *testing.T) {
+	levels := []float64{50, 75, 90}
+	rule := DailyLossRule{MaxLossPercent: 5}
+
+	tests := []struct {
+		name     string
+		equity   float64
+		expected float64 // 0 for no warning
+	}{
+		{name: "In profit", equity: 101000, expected: 0},
+		{name: "Below first level", equity: 97600, expected: 0},
+		{name: "At first level", equity: 97500, expected: 50},
+		{name: "Between levels", equity: 96000, expected: 75},
+		{name: "Highest level", equity: 95400, expected: 90},
+		{name: "Breached rules do not warn", equity: 95000, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := EvaluateWarnings(Snapshot{AccountID: 1, Equity: tt.equity, DayStartEquity: 100000}, []Rule{rule}, levels)
+			if tt.expected == 0 {
+				if len(warnings) != 0 {
+					t.Errorf("expected no warnings, got %+v", warnings)
+				}
+				return
+			}
+			if len(warnings) != 1 || warnings[0].Level != tt.expected {
+				t.Errorf("expected warning at %.0f%%, got %+v", tt.expected, warnings)
+			}
+		})
+	}
+}
+
+func TestMaxLossRule(t *testing.T) {
+	rule := MaxLossRule{MaxLossPercent: 10}
+
+	res := rule.Evaluate(Snapshot{Equity: 91000, InitialBalance: 100000})
+	if res.Breached {
+		t.Errorf("expected no breach at 91000, limit %.2f", res.Limit)
+	}
+
+	res = rule.Evaluate(Snapshot{Equity: 89999, InitialBalance: 100000})
+	if !res.Breached {
+		t.Errorf("expected breach at 89999, limit %.2f", res.Limit)
+	}
+}
+
+func TestTrailingDrawdownRule(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		snapshot      Snapshot
+		expectedLimit float64
+		expected      bool
+	}{
+		{
+			name:          "End of day trails recorded high-water mark",
+			mode:          TrailingEndOfDay,
+			snapshot:      Snapshot{Equity: 104000, InitialBalance: 100000, HighWaterMark: 110000, IntradayHighWaterMark: 120000},
+			expectedLimit: 104500,
+			expected:      true,
+		},
+		{
+			name:          "Intraday trails highest observed equity",
+			mode:          TrailingIntraday,
+			snapshot:      Snapshot{Equity: 114500, InitialBalance: 100000, HighWaterMark: 110000, IntradayHighWaterMark: 120000},
+			expectedLimit: 114000,
+			expected:      false,
+		},
+		{
+			name:          "Intraday includes live equity as a new high",
+			mode:          TrailingIntraday,
+			snapshot:      Snapshot{Equity: 125000, InitialBalance: 100000, HighWaterMark: 110000, IntradayHighWaterMark: 120000},
+			expectedLimit: 118750,
+			expected:      false,
+		},
+		{
+			name:          "High-water mark never below initial balance",
+			mode:          TrailingEndOfDay,
+			snapshot:      Snapshot{Equity: 95000, InitialBalance: 100000},
+			expectedLimit: 95000,
+			expected:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := TrailingDrawdownRule{MaxLossPercent: 5, Mode: tt.mode}
+			res := rule.Evaluate(tt.snapshot)
+			if res.Breached != tt.expected {
+				t.Errorf("Breached = %v, want %v", res.Breached, tt.expected)
+			}
+			if res.Limit != tt.expectedLimit {
+				t.Errorf("Limit = %.2f, want %.2f", res.Limit, tt.expectedLimit)
+			}
+		})
+	}
+}
+
+func TestNewRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "Daily loss", cfg: Config{Type: DailyLoss, LimitPercent: 5}},
+		{name: "Max loss", cfg: Config{Type: MaxLoss, LimitPercent: 10}},
+		{name: "Trailing intraday", cfg: Config{Type: TrailingDrawdown, LimitPercent: 6, TrailingMode: TrailingIntraday}},
+		{name: "Trailing without mode", cfg: Config{Type: TrailingDrawdown, LimitPercent: 6}, wantErr: true},
+		{name: "Unknown type", cfg: Config{Type: "unknown", LimitPercent: 5}, wantErr: true},
+		{name: "Zero limit", cfg: Config{Type: MaxLoss}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := NewRule(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && rule.Name() != tt.cfg.Type {
+				t.Errorf("Name() = %s, want %s", rule.Name(), tt.cfg.Type)
+			}
+		})
+	}
+}